@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-application.antoine.example/internal/models"
+)
+
+// The JSON API lives under /api/v1. It uses the same storage and validation
+// as the HTML handlers; only the encoding of requests and responses differs.
+// Errors are sent as RFC 9457 problem details.
+
+// snippetJSON is the API representation of a snippet. Keeping it separate
+// from models.Snippet means the storage format can change without breaking
+// API clients.
+type snippetJSON struct {
+	ID       int       `json:"id"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+	AuthorID int       `json:"author_id,omitempty"`
+	Tags     []string  `json:"tags"`
+	Language string    `json:"language,omitempty"`
+	Revision int       `json:"revision"`
+	// Updated is left out until the snippet is first edited.
+	Updated *time.Time `json:"updated,omitempty"`
+}
+
+func newSnippetJSON(s models.Snippet) snippetJSON {
+	out := snippetJSON{
+		ID:       s.ID,
+		Title:    s.Title,
+		Content:  s.Content,
+		Created:  s.Created,
+		Expires:  s.Expires,
+		AuthorID: s.AuthorID,
+		Tags:     s.Tags,
+		Language: s.Language,
+		Revision: s.Revision,
+	}
+	if !s.Updated.IsZero() {
+		out.Updated = &s.Updated
+	}
+	// Always send an array, so clients don't have to handle null.
+	if out.Tags == nil {
+		out.Tags = []string{}
+	}
+	return out
+}
+
+// problem is an RFC 9457 problem details object. Errors holds per-field
+// validation messages, when there are any.
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// writeJSON sends data as a JSON response with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	b, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
+
+// writeProblem sends a problem details response.
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.Path
+
+	b, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(append(b, '\n'))
+}
+
+// apiServerError logs the error in the same way as serverError, but sends a
+// problem details response.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+
+	app.writeProblem(w, r, problem{
+		Status: http.StatusInternalServerError,
+		Detail: "The server encountered a problem and could not process your request.",
+	})
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.writeProblem(w, r, problem{
+		Status: http.StatusNotFound,
+		Detail: "The requested resource could not be found.",
+	})
+}
+
+// apiFallback handles every /api/ request that didn't match a route: a 405
+// if the path exists with other methods, or a 404 otherwise.
+func (app *application) apiFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(mux, r)
+		if len(allowed) == 0 {
+			app.apiNotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		app.writeProblem(w, r, problem{
+			Status: http.StatusMethodNotAllowed,
+			Detail: fmt.Sprintf("The %s method is not supported for this resource.", r.Method),
+		})
+	})
+}
+
+// apiRequireAuthentication is the API version of requireAuthentication: it
+// answers with a 401 instead of redirecting to the login page.
+func (app *application) apiRequireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.writeProblem(w, r, problem{
+				Status: http.StatusUnauthorized,
+				Detail: "You must be logged in to access this resource.",
+			})
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxJSONBodyBytes caps the size of API request bodies.
+const maxJSONBodyBytes = 1 << 20
+
+// readJSON decodes a request body into dst. It insists on a JSON content
+// type, rejects unknown fields and trailing data, and turns the decoder's
+// errors into messages which are safe to show to the client.
+func readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			if typeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", typeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", typeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+var errUnsupportedMediaType = errors.New("request body must be application/json")
+
+// readJSONError sends the right problem response for an error from readJSON.
+func (app *application) readJSONError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errUnsupportedMediaType) {
+		status = http.StatusUnsupportedMediaType
+	}
+	app.writeProblem(w, r, problem{Status: status, Detail: err.Error()})
+}
+
+// snippetIDParam returns the {id} path value as a positive integer.
+func snippetIDParam(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// apiSnippetList handles GET /api/v1/snippets. It takes the same author, tag
+// and cursor parameters as the home page, plus page_size.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	q, errs := parseListQuery(r)
+
+	q.Limit = defaultPageSize
+	if v := r.URL.Query().Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			errs["page_size"] = fmt.Sprintf("must be an integer between 1 and %d", maxPageSize)
+		}
+		q.Limit = n
+	}
+	if len(errs) > 0 {
+		app.writeProblem(w, r, problem{
+			Status: http.StatusBadRequest,
+			Detail: "Invalid query parameters.",
+			Errors: errs,
+		})
+		return
+	}
+
+	listing, err := app.listSnippets(q)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	items := make([]snippetJSON, len(listing.Items))
+	for i, item := range listing.Items {
+		items[i] = newSnippetJSON(item.Snippet)
+	}
+
+	// next_cursor is null on the last page.
+	var next *string
+	if listing.Next != "" {
+		next = &listing.Next
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{
+		"snippets": items,
+		"metadata": map[string]any{
+			"page_size":   q.Limit,
+			"next_cursor": next,
+		},
+	})
+}
+
+// apiSnippetCreate handles POST /api/v1/snippets.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title    string   `json:"title"`
+		Content  string   `json:"content"`
+		Expires  int      `json:"expires"`
+		Tags     []string `json:"tags"`
+		Language string   `json:"language"`
+	}
+
+	err := readJSON(w, r, &input)
+	if err != nil {
+		app.readJSONError(w, r, err)
+		return
+	}
+
+	form := snippetForm{
+		Title:    input.Title,
+		Content:  input.Content,
+		Expires:  input.Expires,
+		Tags:     normalizeTags(input.Tags),
+		Language: input.Language,
+	}
+	form.validate()
+
+	if !form.Valid() {
+		app.writeProblem(w, r, problem{
+			Status: http.StatusUnprocessableEntity,
+			Detail: "The snippet failed validation.",
+			Errors: form.FieldErrors,
+		})
+		return
+	}
+
+	authorID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	id, err := app.snippets.Insert(form.newSnippet(authorID))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.metrics.snippetsCreated.Inc()
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, r, http.StatusCreated, map[string]any{"snippet": newSnippetJSON(snippet)})
+}
+
+// apiSnippetGet handles GET /api/v1/snippets/{id}.
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.apiNotFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	app.metrics.snippetViews.Inc()
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"snippet": newSnippetJSON(snippet)})
+}
+
+// apiSnippetDelete handles DELETE /api/v1/snippets/{id}. Only the author of
+// a snippet may delete it.
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.apiNotFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.AuthorID != app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.writeProblem(w, r, problem{
+			Status: http.StatusForbidden,
+			Detail: "Only the author of a snippet can delete it.",
+		})
+		return
+	}
+
+	err = app.snippets.Delete(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// apiDo sends a JSON API request with body as its JSON payload, or no body
+// if it is empty.
+func (c *testClient) apiDo(method, path, body string) (*http.Response, string) {
+	c.t.Helper()
+
+	header := http.Header{}
+	if body != "" {
+		header.Set("Content-Type", "application/json")
+	}
+	return c.do(method, path, header, strings.NewReader(body))
+}
+
+// readProblem decodes a problem details response, failing the test if the
+// response isn't one.
+func readProblem(t *testing.T, res *http.Response, body string) problem {
+	t.Helper()
+
+	assert.Equal(t, res.Header.Get("Content-Type"), "application/problem+json")
+
+	var p problem
+	err := json.Unmarshal([]byte(body), &p)
+	if err != nil {
+		t.Fatalf("decoding %q: %s", body, err)
+	}
+	assert.Equal(t, p.Status, res.StatusCode)
+	return p
+}
+
+// newAPITestServer starts a server for a new test application, with one
+// logged-in client for each of two users.
+func newAPITestServer(t *testing.T) (alice, bob *testClient) {
+	t.Helper()
+
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	alice = newTestClient(t, ts)
+	alice.signup("Alice", "alice@example.com", "correct horse")
+	alice.login("alice@example.com", "correct horse")
+
+	bob = newTestClient(t, ts)
+	bob.signup("Bob", "bob@example.com", "battery staple")
+	bob.login("bob@example.com", "battery staple")
+
+	return alice, bob
+}
+
+// createSnippet creates a snippet through the API and returns its URL.
+func (c *testClient) createSnippet(title, content string) string {
+	c.t.Helper()
+
+	b, _ := json.Marshal(map[string]any{"title": title, "content": content, "expires": 7})
+	res, body := c.apiDo(http.MethodPost, "/api/v1/snippets", string(b))
+	if res.StatusCode != http.StatusCreated {
+		c.t.Fatalf("creating a snippet: got status %d: %s", res.StatusCode, body)
+	}
+	return res.Header.Get("Location")
+}
+
+func TestAPIProblems(t *testing.T) {
+	alice, bob := newAPITestServer(t)
+	anonymous := newTestClient(t, alice.ts)
+	snippet := alice.createSnippet("Alice's", "content")
+
+	tests := []struct {
+		name       string
+		client     *testClient
+		method     string
+		path       string
+		header     http.Header
+		body       string
+		wantStatus int
+		wantDetail string
+		wantErrors map[string]string
+	}{
+		{
+			name:       "Not JSON",
+			client:     alice,
+			method:     http.MethodPost,
+			path:       "/api/v1/snippets",
+			header:     http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:       "title=x&content=y&expires=7",
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantDetail: "request body must be application/json",
+		},
+		{
+			name:       "No content type",
+			client:     alice,
+			method:     http.MethodPatch,
+			path:       snippet,
+			header:     http.Header{},
+			body:       `{"title": "x"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantDetail: "request body must be application/json",
+		},
+		{
+			name:       "Badly-formed JSON",
+			client:     alice,
+			method:     http.MethodPost,
+			path:       "/api/v1/snippets",
+			body:       `{"title": "x",}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "body contains badly-formed JSON (at character 15)",
+		},
+		{
+			name:       "Unknown field",
+			client:     alice,
+			method:     http.MethodPost,
+			path:       "/api/v1/snippets",
+			body:       `{"title": "x", "author_id": 2}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetail: `body contains unknown key "author_id"`,
+		},
+		{
+			name:       "Invalid snippet",
+			client:     alice,
+			method:     http.MethodPost,
+			path:       "/api/v1/snippets",
+			body:       `{"title": "", "content": "y", "expires": 2, "tags": ["Not a tag!"]}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantDetail: "The snippet failed validation.",
+			wantErrors: map[string]string{
+				"title":   "This field cannot be blank",
+				"expires": "This field must equal 1, 7 or 365",
+				"tags":    "Tags must be at most 30 letters, digits or dashes",
+			},
+		},
+		{
+			name:       "Invalid update",
+			client:     alice,
+			method:     http.MethodPatch,
+			path:       snippet,
+			body:       `{"content": "", "language": "cobol"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantDetail: "The snippet failed validation.",
+			wantErrors: map[string]string{
+				"content":  "This field cannot be blank",
+				"language": "This field must be a supported language",
+			},
+		},
+		{
+			name:       "Create when logged out",
+			client:     anonymous,
+			method:     http.MethodPost,
+			path:       "/api/v1/snippets",
+			body:       `{"title": "x", "content": "y", "expires": 7}`,
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "You must be logged in to access this resource.",
+		},
+		{
+			name:       "Update when logged out",
+			client:     anonymous,
+			method:     http.MethodPatch,
+			path:       snippet,
+			body:       `{"title": "x"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "You must be logged in to access this resource.",
+		},
+		{
+			name:       "Delete when logged out",
+			client:     anonymous,
+			method:     http.MethodDelete,
+			path:       snippet,
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "You must be logged in to access this resource.",
+		},
+		{
+			name:       "Update by someone else",
+			client:     bob,
+			method:     http.MethodPatch,
+			path:       snippet,
+			body:       `{"title": "Bob's now"}`,
+			wantStatus: http.StatusForbidden,
+			wantDetail: "Only the author of a snippet can edit it.",
+		},
+		{
+			name:       "Delete by someone else",
+			client:     bob,
+			method:     http.MethodDelete,
+			path:       snippet,
+			wantStatus: http.StatusForbidden,
+			wantDetail: "Only the author of a snippet can delete it.",
+		},
+		{
+			name:       "Missing snippet",
+			client:     alice,
+			method:     http.MethodGet,
+			path:       "/api/v1/snippets/99",
+			wantStatus: http.StatusNotFound,
+			wantDetail: "The requested resource could not be found.",
+		},
+		{
+			name:       "Unknown path",
+			client:     alice,
+			method:     http.MethodGet,
+			path:       "/api/v1/nothing",
+			wantStatus: http.StatusNotFound,
+			wantDetail: "The requested resource could not be found.",
+		},
+		{
+			name:       "Wrong method",
+			client:     alice,
+			method:     http.MethodPut,
+			path:       snippet,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantDetail: "The PUT method is not supported for this resource.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{"Content-Type": {"application/json"}}
+			}
+
+			res, body := tt.client.do(tt.method, tt.path, header, strings.NewReader(tt.body))
+			assert.Equal(t, res.StatusCode, tt.wantStatus)
+
+			p := readProblem(t, res, body)
+			assert.Equal(t, p.Type, "about:blank")
+			assert.Equal(t, p.Title, http.StatusText(tt.wantStatus))
+			assert.Equal(t, p.Detail, tt.wantDetail)
+			assert.Equal(t, p.Instance, tt.path)
+
+			assert.Equal(t, len(p.Errors), len(tt.wantErrors))
+			for field, msg := range tt.wantErrors {
+				assert.Equal(t, p.Errors[field], msg)
+			}
+		})
+	}
+
+	// None of the failed requests changed the snippet.
+	res, body := alice.get(snippet)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.StringContains(t, body, `"title": "Alice's"`)
+	assert.StringContains(t, body, `"revision": 1`)
+}
+
+func TestAPIUpdate(t *testing.T) {
+	alice, _ := newAPITestServer(t)
+	snippet := alice.createSnippet("Old title", "Old content")
+
+	res, body := alice.apiDo(http.MethodPatch, snippet, `{"title": "New title", "tags": ["Go", "go", "tests"]}`)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("Content-Type"), "application/json")
+
+	var got struct {
+		Snippet snippetJSON `json:"snippet"`
+	}
+	err := json.Unmarshal([]byte(body), &got)
+	assert.NilError(t, err)
+
+	// Fields left out of the body keep their values.
+	assert.Equal(t, got.Snippet.Title, "New title")
+	assert.Equal(t, got.Snippet.Content, "Old content")
+	assert.Equal(t, strings.Join(got.Snippet.Tags, ","), "go,tests")
+	assert.Equal(t, got.Snippet.Revision, 2)
+	if got.Snippet.Updated == nil {
+		t.Error("updated wasn't set")
+	}
+
+	// An update which changes nothing succeeds without a new revision.
+	res, body = alice.apiDo(http.MethodPatch, snippet, `{"title": "New title"}`)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.StringContains(t, body, `"revision": 2`)
+}
+
+func TestAPIDelete(t *testing.T) {
+	alice, _ := newAPITestServer(t)
+	snippet := alice.createSnippet("Doomed", "content")
+
+	res, body := alice.apiDo(http.MethodDelete, snippet, "")
+	assert.Equal(t, res.StatusCode, http.StatusNoContent)
+	assert.Equal(t, body, "")
+
+	res, body = alice.get(snippet)
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+	readProblem(t, res, body)
+
+	res, body = alice.apiDo(http.MethodDelete, snippet, "")
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+	readProblem(t, res, body)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// generateCert implements the generate-cert subcommand, which writes a
+// self-signed certificate and private key for local development:
+//
+//	go run . generate-cert -host localhost,127.0.0.1 -dir ./tls
+//
+// Browsers will warn about the certificate, since nothing trusts it. It must
+// never be used in production.
+func generateCert(args []string) error {
+	fs := flag.NewFlagSet("generate-cert", flag.ContinueOnError)
+	hosts := fs.String("host", "localhost,127.0.0.1,::1", "Comma-separated hostnames and IPs to generate a certificate for")
+	dir := fs.String("dir", "./tls", "Directory to write cert.pem and key.pem to")
+	validFor := fs.Duration("duration", 365*24*time.Hour, "How long the certificate is valid for")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating private key: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("generating serial number: %w", err)
+	}
+
+	notBefore := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"Snippetbox Development"}},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(*validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, h := range strings.Split(*hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("creating certificate: %w", err)
+	}
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("encoding private key: %w", err)
+	}
+
+	err = os.MkdirAll(*dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	certPath := filepath.Join(*dir, "cert.pem")
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	if err != nil {
+		return err
+	}
+
+	// The private key is only readable by its owner.
+	keyPath := filepath.Join(*dir, "key.pem")
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), 0o600)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s and %s\n", certPath, keyPath)
+	return nil
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-application.antoine.example/internal/ratelimit"
+)
+
+// config holds every runtime setting for the server. Each field is bound to a
+// command-line flag, and the same name is used for the environment variable
+// and the config file key:
+//
+//	flag:        -session-dir ./data/sessions
+//	environment: SNIPPETBOX_SESSION_DIR=./data/sessions
+//	config file: session-dir = ./data/sessions
+//
+// When a setting is given in more than one place, the precedence is (highest
+// first): command-line flag, environment variable, config file, default.
+//
+// The config file is optional. Its path comes from -config or
+// SNIPPETBOX_CONFIG. It holds one "key = value" pair per line; blank lines
+// and lines starting with # are ignored, values may be double-quoted, and a
+// value may be followed by a # comment. The output of -print-config is a
+// valid config file, with secret options commented out.
+type config struct {
+	addr     string
+	dataDir  string
+	dev      bool
+	logLevel slog.Level
+
+	// adminAddr is where /metrics and /readyz are served. It should not be
+	// reachable from the internet. Empty disables the admin listener.
+	adminAddr string
+
+	// embedAncestors lists the origins which may show snippets in a frame,
+	// as a CSP frame-ancestors source list.
+	embedAncestors string
+
+	// publicURL is the address users reach the site at, such as
+	// "https://snippets.example.com", without a trailing slash. Links meant
+	// for other sites are built from it, rather than from the request,
+	// whose scheme and Host header can't be trusted behind a proxy.
+	publicURL string
+
+	// shutdownTimeout is how long in-flight requests and background
+	// workers get to finish after a shutdown signal. drainDelay comes
+	// before that: readiness fails, but new requests are still accepted
+	// until load balancers have had time to notice.
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+
+	// reapInterval is how often expired snippets are deleted from storage.
+	reapInterval time.Duration
+
+	session struct {
+		dir         string
+		lifetime    time.Duration
+		idleTimeout time.Duration
+	}
+
+	// snippetCache configures the cache in front of snippet lookups. A TTL
+	// of 0 disables it.
+	snippetCache struct {
+		ttl    time.Duration
+		sizeMB int
+	}
+
+	// rateLimit holds the per-client limits for each kind of request.
+	rateLimit struct {
+		read  ratelimit.Limit
+		write ratelimit.Limit
+		login ratelimit.Limit
+	}
+
+	tls struct {
+		certFile     string
+		keyFile      string
+		redirectAddr string
+	}
+
+	// Meta options, which are only read from the command line (and, for
+	// configFile, SNIPPETBOX_CONFIG).
+	configFile  string
+	printConfig bool
+}
+
+// envPrefix is prepended to a flag name (upper-cased, with dashes turned into
+// underscores) to get its environment variable.
+const envPrefix = "SNIPPETBOX_"
+
+// metaOptions can't be set from the environment or a config file.
+var metaOptions = map[string]bool{
+	"config":       true,
+	"print-config": true,
+}
+
+// secretOptions are commented out, without their value, by -print-config,
+// whose output tends to end up in bug reports and chat. tls-key points at the
+// server's private key, so printing it tells a reader exactly what to copy.
+// Any option added later which holds a credential, or the location of one,
+// belongs here too.
+var secretOptions = map[string]bool{
+	"tls-key": true,
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// newFlagSet registers a flag for every config field, with its default.
+func (cfg *config) newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("snippetbox", flag.ContinueOnError)
+
+	fs.StringVar(&cfg.addr, "addr", ":4000", "HTTP network address")
+	// Metrics are served on a separate listener, bound to localhost by
+	// default, so they stay private even when addr is public.
+	fs.StringVar(&cfg.adminAddr, "admin-addr", "localhost:4001", `Admin HTTP address, for /metrics and /readyz ("" to disable)`)
+	// Only the embed view can be framed. Setting this to the origin of,
+	// say, an internal wiki stops other sites from embedding snippets.
+	fs.StringVar(&cfg.embedAncestors, "embed-frame-ancestors", "*", `Origins allowed to embed snippets in a frame, space-separated (e.g. "https://wiki.example.com"), or "*" for any`)
+	// The embed code on each snippet page points here. Behind a proxy which
+	// terminates TLS this is the proxy's https:// address.
+	fs.StringVar(&cfg.publicURL, "public-url", "", `Public base URL of the site, e.g. "https://snippets.example.com" (the embed code is only shown when it is set)`)
+	// Where the snippet and user logs live on disk. They are created on
+	// first start.
+	fs.StringVar(&cfg.dataDir, "data-dir", "./data", "Directory for the snippet and user storage files")
+	// In development, serve static files straight from ./ui/static so edits
+	// show up without rebuilding the binary.
+	fs.BoolVar(&cfg.dev, "dev", false, "Serve static files from disk instead of the embedded copy")
+	fs.TextVar(&cfg.logLevel, "log-level", slog.LevelInfo, "Minimum log level (debug, info, warn or error)")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests to finish on shutdown")
+	fs.DurationVar(&cfg.drainDelay, "drain-delay", 0, "How long to keep accepting requests after failing /readyz on shutdown (e.g. 5s behind a load balancer)")
+	fs.DurationVar(&cfg.reapInterval, "reap-interval", time.Minute, "How often to delete expired snippets from storage")
+
+	// Sessions are kept in memory unless a directory is given for them.
+	fs.StringVar(&cfg.session.dir, "session-dir", "", "Directory for session files (default: keep sessions in memory)")
+	fs.DurationVar(&cfg.session.lifetime, "session-lifetime", 12*time.Hour, "Maximum lifetime of a session")
+	fs.DurationVar(&cfg.session.idleTimeout, "session-idle-timeout", time.Hour, "Expire sessions after this long without a request (0 to disable)")
+
+	fs.DurationVar(&cfg.snippetCache.ttl, "snippet-cache-ttl", time.Minute, "How long to cache looked-up snippets (0 to disable the cache)")
+	fs.IntVar(&cfg.snippetCache.sizeMB, "snippet-cache-size", 32, "Maximum size of the snippet cache, in MB")
+
+	// Limits are written as requests/period: a client can make that many
+	// requests at once, and gets them back gradually over the period.
+	fs.TextVar(&cfg.rateLimit.read, "rate-limit-read", ratelimit.Limit{Requests: 120, Per: time.Minute}, `Per-client limit for GET requests, as requests/period (e.g. "120/1m"), or "off"`)
+	fs.TextVar(&cfg.rateLimit.write, "rate-limit-write", ratelimit.Limit{Requests: 30, Per: time.Minute}, `Per-client limit for POST and DELETE requests, or "off"`)
+	fs.TextVar(&cfg.rateLimit.login, "rate-limit-login", ratelimit.Limit{Requests: 10, Per: 15 * time.Minute}, `Per-client limit for login attempts, or "off"`)
+
+	// Serve HTTPS when both a certificate and a key are given (see the
+	// generate-cert subcommand for development certificates).
+	fs.StringVar(&cfg.tls.certFile, "tls-cert", "", "Path to the TLS certificate (PEM)")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key", "", "Path to the TLS private key (PEM)")
+	// With TLS enabled, optionally listen for plain HTTP as well and
+	// redirect it to HTTPS.
+	fs.StringVar(&cfg.tls.redirectAddr, "http-redirect-addr", "", "Plain HTTP address which redirects to HTTPS, e.g. :4080 (TLS only)")
+
+	fs.StringVar(&cfg.configFile, "config", "", "Path to an optional config file (env: "+envName("config")+")")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "Print the effective configuration and exit")
+
+	return fs
+}
+
+// loadConfig builds the configuration from the command-line arguments, the
+// environment (through lookupEnv) and the config file, then validates it. It
+// also returns where each setting came from, for -print-config.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (config, map[string]string, error) {
+	var cfg config
+	fs := cfg.newFlagSet()
+
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, nil, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	sources := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) { sources[f.Name] = "default" })
+	fs.Visit(func(f *flag.Flag) { sources[f.Name] = "flag" })
+
+	if cfg.configFile == "" {
+		cfg.configFile, _ = lookupEnv(envName("config"))
+		if cfg.configFile != "" {
+			sources["config"] = "env"
+		}
+	}
+
+	// Lower-precedence sources are applied first, and never overwrite a
+	// setting which came from a higher one.
+	if cfg.configFile != "" {
+		f, err := os.Open(cfg.configFile)
+		if err != nil {
+			return cfg, nil, err
+		}
+		defer f.Close()
+
+		err = applyConfigFile(fs, f, sources)
+		if err != nil {
+			return cfg, nil, fmt.Errorf("%s: %w", cfg.configFile, err)
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if envErr != nil || metaOptions[f.Name] || sources[f.Name] == "flag" {
+			return
+		}
+		value, ok := lookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		err := fs.Set(f.Name, value)
+		if err != nil {
+			envErr = fmt.Errorf("%s: %w", envName(f.Name), err)
+			return
+		}
+		sources[f.Name] = "env"
+	})
+	if envErr != nil {
+		return cfg, nil, envErr
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	return cfg, sources, nil
+}
+
+// applyConfigFile sets every option in the config file which hasn't already
+// been given on the command line.
+func applyConfigFile(fs *flag.FlagSet, r io.Reader, sources map[string]string) error {
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		// A value may be followed by a comment, as in the output of
+		// -print-config.
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid quoted value for %s", n, key)
+			}
+			rest := strings.TrimSpace(value[len(quoted):])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				return fmt.Errorf("line %d: unexpected text after quoted value for %s", n, key)
+			}
+			value, _ = strconv.Unquote(quoted)
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+
+		if fs.Lookup(key) == nil || metaOptions[key] {
+			return fmt.Errorf("line %d: unknown option %q", n, key)
+		}
+		if sources[key] == "flag" {
+			continue
+		}
+
+		err := fs.Set(key, value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		sources[key] = "file"
+	}
+
+	return scanner.Err()
+}
+
+// validate checks for settings which are invalid on their own or in
+// combination, so the server refuses to start rather than failing later.
+func (cfg *config) validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(cfg.addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %w", err))
+	}
+	if cfg.adminAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.adminAddr); err != nil {
+			errs = append(errs, fmt.Errorf("admin-addr: %w", err))
+		}
+		if sameListener(cfg.adminAddr, cfg.addr) || sameListener(cfg.adminAddr, cfg.tls.redirectAddr) {
+			errs = append(errs, errors.New("admin-addr: must differ from addr and http-redirect-addr"))
+		}
+	}
+	if cfg.publicURL != "" {
+		u, err := url.Parse(cfg.publicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			errs = append(errs, errors.New(`public-url: must be an http or https URL with no path, such as "https://snippets.example.com"`))
+		}
+		cfg.publicURL = strings.TrimSuffix(cfg.publicURL, "/")
+	}
+	if err := validateFrameAncestors(cfg.embedAncestors); err != nil {
+		errs = append(errs, fmt.Errorf("embed-frame-ancestors: %w", err))
+	}
+	if cfg.dataDir == "" {
+		errs = append(errs, errors.New("data-dir: must not be empty"))
+	}
+
+	if cfg.shutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown-timeout: must be positive"))
+	}
+	if cfg.drainDelay < 0 {
+		errs = append(errs, errors.New("drain-delay: must not be negative"))
+	}
+
+	if cfg.reapInterval <= 0 {
+		errs = append(errs, errors.New("reap-interval: must be positive"))
+	}
+
+	if cfg.snippetCache.ttl < 0 {
+		errs = append(errs, errors.New("snippet-cache-ttl: must not be negative"))
+	}
+	if cfg.snippetCache.sizeMB < 1 {
+		errs = append(errs, errors.New("snippet-cache-size: must be at least 1"))
+	}
+
+	if cfg.session.lifetime <= 0 {
+		errs = append(errs, errors.New("session-lifetime: must be positive"))
+	}
+	if cfg.session.idleTimeout < 0 {
+		errs = append(errs, errors.New("session-idle-timeout: must not be negative"))
+	}
+	if cfg.session.idleTimeout > cfg.session.lifetime {
+		errs = append(errs, errors.New("session-idle-timeout: must not be longer than session-lifetime"))
+	}
+
+	if (cfg.tls.certFile == "") != (cfg.tls.keyFile == "") {
+		errs = append(errs, errors.New("tls-cert and tls-key must be given together"))
+	}
+	if cfg.tls.redirectAddr != "" {
+		if !cfg.useTLS() {
+			errs = append(errs, errors.New("http-redirect-addr: requires tls-cert and tls-key"))
+		}
+		if _, _, err := net.SplitHostPort(cfg.tls.redirectAddr); err != nil {
+			errs = append(errs, fmt.Errorf("http-redirect-addr: %w", err))
+		}
+		if sameListener(cfg.tls.redirectAddr, cfg.addr) {
+			errs = append(errs, errors.New("http-redirect-addr: must differ from addr"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sameListener reports whether listening on a and on b would clash: they
+// have the same port, and either one listens on every interface (":4000")
+// or their hosts resolve to a common address ("localhost" and "127.0.0.1").
+// A host which doesn't resolve can't clash; the listener reports it.
+func sameListener(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+
+	// Port 0 picks a free port, and named ports ("http") are resolved.
+	numA, errA := net.LookupPort("tcp", portA)
+	numB, errB := net.LookupPort("tcp", portB)
+	if errA != nil || errB != nil || numA != numB || numA == 0 {
+		return false
+	}
+
+	ipsA := resolveListenHost(hostA)
+	ipsB := resolveListenHost(hostB)
+	for _, ipA := range ipsA {
+		for _, ipB := range ipsB {
+			if ipA.IsUnspecified() || ipB.IsUnspecified() || ipA.Equal(ipB) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveListenHost returns the addresses a listener on host would use. An
+// empty host means every interface.
+func resolveListenHost(host string) []net.IP {
+	if host == "" {
+		return []net.IP{net.IPv4zero}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	return ips
+}
+
+// validateFrameAncestors checks a list of origins for the frame-ancestors
+// directive. Only "*", 'self' and plain http(s) origins are accepted, which
+// also rules out anything that would break out of the directive, such as a
+// semicolon.
+func validateFrameAncestors(s string) error {
+	sources := strings.Fields(s)
+	if len(sources) == 0 {
+		return errors.New(`must not be empty (use "'none'" to disallow framing)`)
+	}
+
+	for _, source := range sources {
+		if source == "*" || source == "'self'" || source == "'none'" {
+			if source == "'none'" && len(sources) > 1 {
+				return errors.New("'none' must be used on its own")
+			}
+			continue
+		}
+
+		u, err := url.Parse(source)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("%q is not an origin such as https://wiki.example.com", source)
+		}
+		if strings.ContainsAny(source, ";,'\"") {
+			return fmt.Errorf("%q contains invalid characters", source)
+		}
+	}
+	return nil
+}
+
+// useTLS reports whether the server should serve HTTPS.
+func (cfg *config) useTLS() bool {
+	return cfg.tls.certFile != "" && cfg.tls.keyFile != ""
+}
+
+// write prints the effective configuration in config file format, with the
+// source of each value as a comment. Secret options which are set come out
+// as a comment instead, so the output can still be loaded as a config file
+// (once the secret has been given some other way).
+func (cfg *config) write(w io.Writer, sources map[string]string) {
+	// Binding a fresh flag set sets its fields to the defaults, so copy the
+	// effective values in afterwards. The flags then read them back as
+	// strings.
+	var current config
+	fs := current.newFlagSet()
+	current = *cfg
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if metaOptions[f.Name] {
+			return
+		}
+		if secretOptions[f.Name] && f.Value.String() != "" {
+			fmt.Fprintf(w, "# %s = (redacted) # %s\n", f.Name, sources[f.Name])
+			return
+		}
+		fmt.Fprintf(w, "%s = %s # %s\n", f.Name, strconv.Quote(f.Value.String()), sources[f.Name])
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// mapEnv returns a lookupEnv for loadConfig which reads from env.
+func mapEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+// writeConfigFile writes content to a config file in a temporary directory
+// and returns its path.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "snippetbox.conf")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		flag       bool
+		env        bool
+		file       bool
+		wantAddr   string
+		wantSource string
+	}{
+		{"Default", false, false, false, ":4000", "default"},
+		{"File", false, false, true, ":4002", "file"},
+		{"Environment over file", false, true, true, ":4003", "env"},
+		{"Flag over environment", true, true, true, ":4004", "flag"},
+		{"Flag over file", true, false, true, ":4004", "flag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			env := map[string]string{}
+
+			if tt.file {
+				args = append(args, "-config", writeConfigFile(t, "addr = :4002\n"))
+			}
+			if tt.env {
+				env["SNIPPETBOX_ADDR"] = ":4003"
+			}
+			if tt.flag {
+				args = append(args, "-addr", ":4004")
+			}
+
+			cfg, sources, err := loadConfig(args, mapEnv(env))
+			assert.NilError(t, err)
+			assert.Equal(t, cfg.addr, tt.wantAddr)
+			assert.Equal(t, sources["addr"], tt.wantSource)
+		})
+	}
+}
+
+func TestLoadConfigSources(t *testing.T) {
+	path := writeConfigFile(t, `
+# A comment, then a quoted value with a comment after it.
+log-level = "debug" # from a previous -print-config
+shutdown-timeout = 5s
+rate-limit-login = 5/10m
+`)
+
+	cfg, sources, err := loadConfig([]string{"-addr", ":5000"}, mapEnv(map[string]string{
+		"SNIPPETBOX_CONFIG":        path,
+		"SNIPPETBOX_DATA_DIR":      "/var/lib/snippetbox",
+		"SNIPPETBOX_SESSION_DIR":   "/var/lib/snippetbox/sessions",
+		"SNIPPETBOX_PRINT_CONFIG":  "true", // meta options only come from flags
+		"SNIPPETBOX_UNRELATED_VAR": "ignored",
+	}))
+	assert.NilError(t, err)
+
+	assert.Equal(t, cfg.addr, ":5000")
+	assert.Equal(t, cfg.logLevel, slog.LevelDebug)
+	assert.Equal(t, cfg.shutdownTimeout.String(), "5s")
+	assert.Equal(t, cfg.rateLimit.login.String(), "5/10m")
+	assert.Equal(t, cfg.dataDir, "/var/lib/snippetbox")
+	assert.Equal(t, cfg.session.dir, "/var/lib/snippetbox/sessions")
+	assert.Equal(t, cfg.printConfig, false)
+
+	assert.Equal(t, sources["config"], "env")
+	assert.Equal(t, sources["log-level"], "file")
+	assert.Equal(t, sources["data-dir"], "env")
+	assert.Equal(t, sources["reap-interval"], "default")
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr string
+	}{
+		{
+			name:    "Unknown flag",
+			args:    []string{"-no-such-option"},
+			wantErr: "flag provided but not defined",
+		},
+		{
+			name:    "Invalid environment value",
+			env:     map[string]string{"SNIPPETBOX_SHUTDOWN_TIMEOUT": "soon"},
+			wantErr: "SNIPPETBOX_SHUTDOWN_TIMEOUT",
+		},
+		{
+			name:    "Unknown file option",
+			file:    "colour = blue\n",
+			wantErr: `line 1: unknown option "colour"`,
+		},
+		{
+			name:    "Meta option in file",
+			file:    "print-config = true\n",
+			wantErr: `unknown option "print-config"`,
+		},
+		{
+			name:    "Missing equals sign",
+			file:    "\naddr :4000\n",
+			wantErr: "line 2: expected key = value",
+		},
+		{
+			name:    "TLS key without certificate",
+			args:    []string{"-tls-key", "key.pem"},
+			wantErr: "tls-cert and tls-key must be given together",
+		},
+		{
+			name:    "Admin address overlapping addr",
+			args:    []string{"-addr", ":4000", "-admin-addr", "localhost:4000"},
+			wantErr: "admin-addr: must differ from addr",
+		},
+		{
+			name:    "Public URL with a path",
+			args:    []string{"-public-url", "https://example.com/snippets"},
+			wantErr: "public-url: must be an http or https URL with no path",
+		},
+		{
+			name:    "Public URL without a scheme",
+			args:    []string{"-public-url", "snippets.example.com"},
+			wantErr: "public-url: must be an http or https URL with no path",
+		},
+		{
+			name:    "Several problems at once",
+			args:    []string{"-addr", "nowhere", "-session-lifetime", "1h", "-session-idle-timeout", "2h"},
+			wantErr: "session-idle-timeout: must not be longer than session-lifetime",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeConfigFile(t, tt.file))
+			}
+
+			_, _, err := loadConfig(args, mapEnv(tt.env))
+			if err == nil {
+				t.Fatalf("got no error; want one containing %q", tt.wantErr)
+			}
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestPrintConfigRedactsSecrets(t *testing.T) {
+	cfg, sources, err := loadConfig([]string{
+		"-tls-cert", "/etc/snippetbox/cert.pem",
+		"-tls-key", "/etc/snippetbox/key.pem",
+		"-session-dir", "/var/lib/snippetbox/sessions",
+	}, noEnv)
+	assert.NilError(t, err)
+
+	var buf bytes.Buffer
+	cfg.write(&buf, sources)
+	out := buf.String()
+
+	assert.StringContains(t, out, "\n# tls-key = (redacted) # flag\n")
+	assert.StringContains(t, out, `tls-cert = "/etc/snippetbox/cert.pem" # flag`)
+	assert.StringContains(t, out, `session-dir = "/var/lib/snippetbox/sessions" # flag`)
+	assert.StringContains(t, out, `addr = ":4000" # default`)
+	if strings.Contains(out, "key.pem") {
+		t.Errorf("output contains the key path:\n%s", out)
+	}
+}
+
+// TestPrintConfigRoundTrip checks that the output of -print-config can be
+// loaded back as a config file, giving the same configuration once the
+// redacted secret is supplied again.
+func TestPrintConfigRoundTrip(t *testing.T) {
+	cfg, sources, err := loadConfig([]string{
+		"-addr", ":5000",
+		"-log-level", "warn",
+		"-rate-limit-read", "off",
+		"-embed-frame-ancestors", "https://wiki.example.com 'self'",
+		"-public-url", "https://snippets.example.com/",
+		"-session-dir", "/var/lib/snippetbox/sessions",
+		"-tls-cert", "/etc/snippetbox/cert.pem",
+		"-tls-key", "/etc/snippetbox/key.pem",
+	}, noEnv)
+	assert.NilError(t, err)
+
+	var buf bytes.Buffer
+	cfg.write(&buf, sources)
+	path := writeConfigFile(t, buf.String())
+
+	// Without the key, the certificate is on its own.
+	_, _, err = loadConfig([]string{"-config", path}, noEnv)
+	if err == nil {
+		t.Fatal("loaded a config with tls-cert but no tls-key")
+	}
+	assert.StringContains(t, err.Error(), "tls-cert and tls-key must be given together")
+
+	loaded, _, err := loadConfig([]string{"-config", path}, mapEnv(map[string]string{
+		"SNIPPETBOX_TLS_KEY": "/etc/snippetbox/key.pem",
+	}))
+	assert.NilError(t, err)
+	loaded.configFile = ""
+	assert.Equal(t, loaded, cfg)
+}
+
+func TestSameListener(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{":4000", ":4000", true},
+		{":4000", "localhost:4000", true},
+		{"0.0.0.0:4000", "127.0.0.1:4000", true},
+		{"[::]:4000", "127.0.0.1:4000", true},
+		{"localhost:4000", "127.0.0.1:4000", true},
+		{"127.0.0.1:http", "127.0.0.1:80", true},
+		{"127.0.0.1:4000", "127.0.0.2:4000", false},
+		{":4000", ":4001", false},
+		{":0", ":0", false},
+		{":4000", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, sameListener(tt.a, tt.b), tt.want)
+			assert.Equal(t, sameListener(tt.b, tt.a), tt.want)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+// contextKey is the type used for the keys of values the application stores
+// in a request context, so they can't collide with keys from other packages.
+type contextKey string
+
+const isAuthenticatedContextKey = contextKey("isAuthenticated")
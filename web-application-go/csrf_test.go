@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+func TestCSRF(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	client := newTestClient(t, ts)
+	token := client.csrfToken("/user/signup")
+	otherToken := newTestClient(t, ts).csrfToken("/user/signup")
+
+	form := func(token string) url.Values {
+		v := url.Values{
+			"name":     {"Alice"},
+			"email":    {"alice@example.com"},
+			"password": {"correct horse"},
+		}
+		if token != "" {
+			v.Set("csrf_token", token)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name          string
+		form          url.Values
+		header        http.Header
+		wantStatus    int
+		wantLocation  string
+		wantForbidden bool
+	}{
+		{
+			name:          "Missing token",
+			form:          form(""),
+			wantForbidden: true,
+		},
+		{
+			name:          "Wrong token",
+			form:          form("not-the-token"),
+			wantForbidden: true,
+		},
+		{
+			name:          "Token from another session",
+			form:          form(otherToken),
+			wantForbidden: true,
+		},
+		{
+			name:          "Cross-origin",
+			form:          form(token),
+			header:        http.Header{"Sec-Fetch-Site": {"cross-site"}},
+			wantForbidden: true,
+		},
+		{
+			name:          "Cross-origin by Origin header",
+			form:          form(token),
+			header:        http.Header{"Origin": {"https://evil.example.com"}},
+			wantForbidden: true,
+		},
+		{
+			name:         "Valid token in the form",
+			form:         form(token),
+			header:       http.Header{"Sec-Fetch-Site": {"same-origin"}},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			// The email is taken now, so the form comes back with an error
+			// rather than a redirect.
+			name:       "Valid token in the header",
+			form:       form(""),
+			header:     http.Header{"X-Csrf-Token": {token}},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+			for name, values := range tt.header {
+				header[name] = values
+			}
+
+			res, body := client.do(http.MethodPost, "/user/signup", header, strings.NewReader(tt.form.Encode()))
+			if tt.wantForbidden {
+				assert.Equal(t, res.StatusCode, http.StatusForbidden)
+				assert.StringContains(t, body, "Request blocked")
+				return
+			}
+			assert.Equal(t, res.StatusCode, tt.wantStatus)
+			assert.Equal(t, res.Header.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+// TestCSRFSafeMethods checks that requests which don't change anything
+// aren't checked, even cross-origin.
+func TestCSRFSafeMethods(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	res, _ := newTestClient(t, ts).do(http.MethodGet, "/user/signup", http.Header{"Sec-Fetch-Site": {"cross-site"}}, nil)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+// TestCSRFAPI checks that the API answers a cross-origin write with problem
+// details rather than the HTML page.
+func TestCSRFAPI(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	res, body := newTestClient(t, ts).do(http.MethodPost, "/api/v1/snippets", http.Header{
+		"Content-Type":   {"application/json"},
+		"Sec-Fetch-Site": {"cross-site"},
+	}, strings.NewReader(`{"title": "x", "content": "y", "expires": 1}`))
+
+	assert.Equal(t, res.StatusCode, http.StatusForbidden)
+	assert.Equal(t, res.Header.Get("Content-Type"), "application/problem+json")
+	assert.StringContains(t, body, `"status": 403`)
+	assert.StringContains(t, body, `"title": "Forbidden"`)
+}
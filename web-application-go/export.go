@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"web-application.antoine.example/internal/highlight"
+	"web-application.antoine.example/internal/models"
+)
+
+// Besides the HTML page, a snippet can be fetched as plain text (raw), saved
+// as a file (download) or shown inside another site's page (embed). The
+// embed view is the only page on the site which may be framed: the page
+// which embeds it adds ui/static/js/embed.js, which inserts an iframe and
+// resizes it to fit the snippet.
+
+// maxFilenameSlug is the longest a download's file name can be, in
+// characters, not counting the extension.
+const maxFilenameSlug = 60
+
+// snippetFromPath returns the snippet whose ID is in the path, sending a 404
+// and returning false if there isn't one.
+func (app *application) snippetFromPath(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.notFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+	app.metrics.snippetViews.Inc()
+
+	return snippet, true
+}
+
+// snippetRaw handles GET /snippet/raw/{id}, sending the content exactly as it
+// was saved. The nosniff header set by secureHeaders stops browsers treating
+// it as anything other than text, whatever it contains.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(snippet.Content))
+}
+
+// snippetDownload handles GET /snippet/download/{id}. It sends the same body
+// as snippetRaw, with a Content-Disposition header that makes the browser
+// save it under a name taken from the title and the language's extension.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": downloadFilename(snippet)}))
+	w.Write([]byte(snippet.Content))
+}
+
+// downloadFilename returns the file name for a downloaded snippet, such as
+// "parse-config-files.go": the title, lower-cased, with each run of
+// characters other than letters and digits turned into a dash. Titles with
+// nothing left fall back to "snippet-<id>". Non-ASCII names are sent with
+// the RFC 2231 encoding, which mime.FormatMediaType takes care of.
+func downloadFilename(snippet models.Snippet) string {
+	var b strings.Builder
+	n, dash := 0, false
+	for _, c := range strings.ToLower(snippet.Title) {
+		if n == maxFilenameSlug {
+			break
+		}
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			dash = true
+			continue
+		}
+		if dash && n > 0 {
+			b.WriteByte('-')
+			n++
+		}
+		b.WriteRune(c)
+		n++
+		dash = false
+	}
+
+	name := strings.TrimSuffix(b.String(), "-")
+	if name == "" {
+		name = "snippet-" + strconv.Itoa(snippet.ID)
+	}
+
+	ext := ".txt"
+	if l, ok := highlight.Lookup(snippet.Language); ok {
+		ext = l.Extension
+	}
+	return name + ext
+}
+
+// snippetEmbed handles GET /snippet/embed/{id}, a page with nothing but the
+// snippet, for showing in an iframe. It has no session: cookies in a frame on
+// another site are third-party, and a page that can be framed must not act
+// on the user's behalf anyway.
+func (app *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	data := templateData{
+		CurrentYear: time.Now().Year(),
+		Snippet:     snippet,
+	}
+	if snippet.Language == "markdown" {
+		data.Rendered = app.markdown.Render(snippet.ID, snippet.Language, snippet.Content)
+	} else {
+		data.Highlighted = app.highlights.Render(snippet.ID, snippet.Language, snippet.Content)
+	}
+
+	app.render(w, r, http.StatusOK, "embed.tmpl", data)
+}
+
+// embedCode returns the HTML another site pastes in to embed a snippet, or
+// an empty string if -public-url isn't set. It uses the unfingerprinted
+// script URL, which stays the same across releases.
+func (app *application) embedCode(id int) string {
+	if app.publicURL == "" {
+		return ""
+	}
+	return `<script src="` + app.publicURL + `/static/js/embed.js" data-snippet="` + strconv.Itoa(id) + `" async></script>`
+}
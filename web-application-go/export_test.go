@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+	"web-application.antoine.example/internal/models"
+)
+
+// TestEmbedCode checks that the embed code on the view page is built from
+// -public-url, whatever Host header the request came with.
+func TestEmbedCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		want      string
+	}{
+		{"Configured", "https://snippets.example.com", `src="https://snippets.example.com/static/js/embed.js" data-snippet="1"`},
+		{"Not configured", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, routes := newTestApplication(t)
+			app.publicURL = tt.publicURL
+			ts := newTestServer(t, routes)
+
+			_, err := app.snippets.Insert(models.NewSnippet{Title: "Go", Content: "package main", Expires: 7})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Host = "evil.example.com"
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, res.StatusCode, http.StatusOK)
+			page := strings.ReplaceAll(string(body), "&#34;", `"`)
+			if strings.Contains(page, "evil.example.com") {
+				t.Errorf("page contains the request's Host header")
+			}
+			if tt.want == "" {
+				if strings.Contains(page, "embed-code") {
+					t.Errorf("page shows the embed code without -public-url")
+				}
+				return
+			}
+			assert.StringContains(t, page, tt.want)
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	content := "<script>alert(1)</script>\n<p>Ünïcödé & more</p>"
+	_, err := app.snippets.Insert(models.NewSnippet{Title: "HTML", Content: content, Expires: 7, Language: "html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, body := newTestClient(t, ts).get("/snippet/raw/1")
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, res.Header.Get("X-Content-Type-Options"), "nosniff")
+	assert.Equal(t, res.Header.Get("Content-Disposition"), "")
+	assert.Equal(t, body, content)
+
+	res, _ = newTestClient(t, ts).get("/snippet/raw/2")
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestSnippetDownload(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		language string
+		want     string
+	}{
+		{"Plain title", "Parse config files", "go", `attachment; filename=parse-config-files.go`},
+		{"No language", "Notes", "", `attachment; filename=notes.txt`},
+		{"Quotes", `Say "hello"`, "", `attachment; filename=say-hello.txt`},
+		{"Slashes", `../../etc/passwd\..\boot.ini`, "", `attachment; filename=etc-passwd-boot-ini.txt`},
+		{"Header injection", "a\r\nSet-Cookie: x=y; filename=evil.exe", "", `attachment; filename=a-set-cookie-x-y-filename-evil-exe.txt`},
+		{"Non-ASCII", "Été à Paris", "markdown", `attachment; filename*=utf-8''%C3%A9t%C3%A9-%C3%A0-paris.md`},
+		{"Nothing left", `"/\..`, "", `attachment; filename=snippet-1.txt`},
+		{"Long", strings.Repeat("a", 100), "", `attachment; filename=` + strings.Repeat("a", maxFilenameSlug) + `.txt`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, routes := newTestApplication(t)
+			ts := newTestServer(t, routes)
+
+			_, err := app.snippets.Insert(models.NewSnippet{Title: tt.title, Content: "content", Expires: 7, Language: tt.language})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, body := newTestClient(t, ts).get("/snippet/download/1")
+			assert.Equal(t, res.StatusCode, http.StatusOK)
+			assert.Equal(t, res.Header.Get("Content-Type"), "text/plain; charset=utf-8")
+			assert.Equal(t, res.Header.Get("X-Content-Type-Options"), "nosniff")
+			assert.Equal(t, res.Header.Get("Content-Disposition"), tt.want)
+			assert.Equal(t, body, "content")
+
+			// Whatever the title, the name a browser reads back is a
+			// single path element.
+			_, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition"))
+			assert.NilError(t, err)
+			if name := params["filename"]; name == "" || strings.ContainsAny(name, `/\"`) || strings.HasPrefix(name, ".") {
+				t.Errorf("unsafe file name %q", name)
+			}
+		})
+	}
+}
+
+// TestFraming checks that the embed view is the only page which may be shown
+// in a frame, and that every other route keeps denying it.
+func TestFraming(t *testing.T) {
+	app, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	_, err := app.snippets.Insert(models.NewSnippet{Title: "Go", Content: "package main", Expires: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{"/no/such/page", "/api/v1/nothing", "/snippet/embed/99"}
+	for _, tt := range routeTests {
+		if tt.headStatus != 0 {
+			urls = append(urls, tt.url)
+		}
+	}
+
+	for _, url := range urls {
+		t.Run(url, func(t *testing.T) {
+			res, _ := newTestClient(t, ts).get(url)
+
+			csp := res.Header.Get("Content-Security-Policy")
+			if strings.HasPrefix(url, "/snippet/embed/") {
+				assert.Equal(t, csp, contentSecurityPolicy+"; frame-ancestors "+app.embedAncestors)
+				assert.Equal(t, res.Header.Get("X-Frame-Options"), "")
+				return
+			}
+			assert.Equal(t, csp, contentSecurityPolicy+"; frame-ancestors 'none'")
+			assert.Equal(t, res.Header.Get("X-Frame-Options"), "deny")
+		})
+	}
+}
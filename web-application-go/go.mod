@@ -1,3 +1,9 @@
 module web-application.antoine.example
 
 go 1.25.1
+
+require (
+	github.com/yuin/goldmark v1.8.6
+	golang.org/x/crypto v0.45.0
+	golang.org/x/text v0.31.0
+)
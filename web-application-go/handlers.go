@@ -0,0 +1,402 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"web-application.antoine.example/internal/highlight"
+	"web-application.antoine.example/internal/models"
+	"web-application.antoine.example/internal/validator"
+)
+
+func (app *application) home(w http.ResponseWriter, r *http.Request) {
+	// A malformed filter or cursor can only come from an edited link, so
+	// there is no need to explain it beyond a 400.
+	q, errs := parseListQuery(r)
+	if len(errs) > 0 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	q.Limit = homePageSize
+
+	listing, err := app.listSnippets(q)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Listing = listing
+
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
+}
+
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	// The ID can come from the path (/snippet/view/123) or from the query
+	// string (/snippet/view?id=123). Anything that isn't a positive integer
+	// can't match a snippet, so it gets a 404 straight away.
+	idParam := r.PathValue("id")
+	if idParam == "" {
+		idParam = r.URL.Query().Get("id")
+	}
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.metrics.snippetViews.Inc()
+
+	author, err := app.authorName(snippet.AuthorID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Author = author
+	data.CanEdit = app.canEdit(r, snippet)
+	data.EmbedCode = app.embedCode(snippet.ID)
+
+	// Markdown snippets are notes, so they are shown rendered unless the
+	// source was asked for with ?source=1. Everything else is shown as
+	// highlighted code.
+	if snippet.Language == "markdown" && r.URL.Query().Get("source") == "" {
+		data.Rendered = app.markdown.Render(snippet.ID, snippet.Language, snippet.Content)
+	} else {
+		data.Highlighted = app.highlights.Render(snippet.ID, snippet.Language, snippet.Content)
+	}
+
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+// snippetForm holds the values submitted in the create or edit form, along
+// with any validation errors for them. The struct is passed back to the form
+// template so the user sees their input again next to the error messages.
+// Expires is only used when creating a snippet.
+type snippetForm struct {
+	Title   string
+	Content string
+	Expires int
+	Tags    []string
+	// Language is empty if the user left it to be detected.
+	Language string
+	validator.Validator
+}
+
+// tagRX matches a valid tag: lowercase letters, digits and dashes, starting
+// with a letter or digit.
+var tagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+const (
+	maxTags      = 5
+	maxTagLength = 30
+)
+
+// parseTags splits a comma or space separated list of tags, as typed into the
+// create form, and normalises it with normalizeTags.
+func parseTags(s string) []string {
+	return normalizeTags(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}))
+}
+
+// normalizeTags lowercases tags and drops blanks and duplicates, keeping the
+// order they were given in.
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// validate checks the rules for a new snippet. The JSON API uses it too, so
+// both ways of creating a snippet accept exactly the same input.
+func (form *snippetForm) validate() {
+	form.validateContent()
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+}
+
+// validateContent checks the fields which can be edited after a snippet is
+// created.
+func (form *snippetForm) validateContent() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(len(form.Tags) <= maxTags, "tags", fmt.Sprintf("This field cannot have more than %d tags", maxTags))
+	for _, tag := range form.Tags {
+		form.CheckField(validator.Matches(tag, tagRX) && validator.MaxChars(tag, maxTagLength), "tags",
+			fmt.Sprintf("Tags must be at most %d letters, digits or dashes", maxTagLength))
+	}
+	if form.Language != "" {
+		_, ok := highlight.Lookup(form.Language)
+		form.CheckField(ok, "language", "This field must be a supported language")
+	}
+}
+
+// language returns the canonical name of the chosen language, or detects one
+// from the content if none was chosen.
+func (form *snippetForm) language() string {
+	if l, ok := highlight.Lookup(form.Language); ok {
+		return l.Name
+	}
+	return highlight.Detect(form.Content)
+}
+
+// newSnippet returns the validated form as a snippet by the given author.
+func (form *snippetForm) newSnippet(authorID int) models.NewSnippet {
+	return models.NewSnippet{
+		Title:    form.Title,
+		Content:  form.Content,
+		Expires:  form.Expires,
+		AuthorID: authorID,
+		Tags:     form.Tags,
+		Language: form.language(),
+	}
+}
+
+// snippetUpdate returns the validated form as an edit by the given user.
+func (form *snippetForm) snippetUpdate(authorID int) models.SnippetUpdate {
+	return models.SnippetUpdate{
+		Title:    form.Title,
+		Content:  form.Content,
+		AuthorID: authorID,
+		Tags:     form.Tags,
+		Language: form.language(),
+	}
+}
+
+// formContent returns the content field of a form. Browsers send the lines
+// of a textarea separated by CRLF; they are stored with plain newlines, so
+// that a snippet reads the same whether it came from the form or the API and
+// revisions can be diffed line by line.
+func formContent(r *http.Request) string {
+	return strings.ReplaceAll(r.PostForm.Get("content"), "\r\n", "\n")
+}
+
+func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	// Default the expiry to one year, which is the option selected when the
+	// form is first shown.
+	data := app.newTemplateData(r)
+	data.Form = snippetForm{Expires: 365}
+
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
+}
+
+func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
+	// ParseForm() adds any data in POST request bodies to the r.PostForm map.
+	// A malformed body is the client's fault, so it gets a 400.
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The expiry comes through as a string, so convert it. A value that isn't
+	// a number is left as 0 and rejected by the PermittedValue check below.
+	expires, _ := strconv.Atoi(r.PostForm.Get("expires"))
+
+	form := snippetForm{
+		Title:    r.PostForm.Get("title"),
+		Content:  formContent(r),
+		Expires:  expires,
+		Tags:     parseTags(r.PostForm.Get("tags")),
+		Language: r.PostForm.Get("language"),
+	}
+
+	form.validate()
+
+	// If there are any errors, redisplay the form with a 422 status code and
+	// the data the user already entered.
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return
+	}
+
+	// requireAuthentication guarantees there is a logged-in user here.
+	authorID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	id, err := app.snippets.Insert(form.newSnippet(authorID))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.metrics.snippetsCreated.Inc()
+
+	// Add a one-time message to the session. It is shown (and removed) by
+	// the next page that renders, which is the snippet we redirect to.
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
+// userSignupForm holds the values submitted in the signup form. The password
+// is never sent back to the template.
+type userSignupForm struct {
+	Name     string
+	Email    string
+	Password string
+	validator.Validator
+}
+
+func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userSignupForm{}
+
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
+}
+
+func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userSignupForm{
+		Name:     r.PostForm.Get("name"),
+		Email:    r.PostForm.Get("email"),
+		Password: r.PostForm.Get("password"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(validator.MaxBytes(form.Password, 72), "password", "This field cannot be more than 72 bytes long")
+
+	if !form.Valid() {
+		form.Password = ""
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		return
+	}
+
+	_, err = app.users.Insert(form.Name, form.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.AddFieldError("email", "Email address is already in use")
+			form.Password = ""
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// userLoginForm holds the values submitted in the login form.
+type userLoginForm struct {
+	Email    string
+	Password string
+	validator.Validator
+}
+
+func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userLoginForm{}
+
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
+}
+
+func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userLoginForm{
+		Email:    r.PostForm.Get("email"),
+		Password: r.PostForm.Get("password"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		form.Password = ""
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+		return
+	}
+
+	id, err := app.users.Authenticate(form.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldError("Email or password is incorrect")
+			form.Password = ""
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Logging in changes the privilege level of the session, so give it a
+	// new token before storing the user ID in it.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Also start a fresh CSRF token for the new privilege level.
+	app.sessionManager.Remove(r.Context(), csrfSessionKey)
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+
+	// Send the user back to the page which made them log in, if any.
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	http.Redirect(w, r, safeRedirectPath(path, "/snippet/create"), http.StatusSeeOther)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
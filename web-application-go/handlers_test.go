@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+func TestUserSignupDuplicateEmail(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	client := newTestClient(t, ts)
+	client.signup("Alice", "alice@example.com", "correct horse")
+
+	res, body := client.postForm("/user/signup", url.Values{
+		"name":       {"Another Alice"},
+		"email":      {"alice@example.com"},
+		"password":   {"battery staple"},
+		"csrf_token": {client.csrfToken("/user/signup")},
+	})
+	assert.Equal(t, res.StatusCode, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Email address is already in use")
+	// The rest of the form is kept, apart from the password.
+	assert.StringContains(t, body, `value="Another Alice"`)
+	if strings.Contains(body, "battery staple") {
+		t.Error("the password was sent back in the form")
+	}
+}
+
+func TestUserLoginRedirect(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+	newTestClient(t, ts).signup("Alice", "alice@example.com", "correct horse")
+
+	t.Run("Back to the page which asked", func(t *testing.T) {
+		client := newTestClient(t, ts)
+
+		res, _ := client.get("/snippet/create?from=nav")
+		assert.Equal(t, res.StatusCode, http.StatusSeeOther)
+		assert.Equal(t, res.Header.Get("Location"), "/user/login")
+
+		res = client.login("alice@example.com", "correct horse")
+		assert.Equal(t, res.Header.Get("Location"), "/snippet/create?from=nav")
+
+		// The path is only used once.
+		client.postForm("/user/logout", url.Values{"csrf_token": {client.csrfToken("/snippet/create")}})
+		res = client.login("alice@example.com", "correct horse")
+		assert.Equal(t, res.Header.Get("Location"), "/snippet/create")
+	})
+
+	t.Run("Default page", func(t *testing.T) {
+		res := newTestClient(t, ts).login("alice@example.com", "correct horse")
+		assert.Equal(t, res.Header.Get("Location"), "/snippet/create")
+	})
+}
+
+func TestUserLogout(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	client := newTestClient(t, ts)
+	client.signup("Alice", "alice@example.com", "correct horse")
+	client.login("alice@example.com", "correct horse")
+
+	res, _ := client.get("/snippet/create")
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	// Keep the logged-in session cookie, as someone who copied it would.
+	u, err := url.Parse(ts.URL)
+	assert.NilError(t, err)
+	stolen := client.client.Jar.Cookies(u)
+
+	res, _ = client.postForm("/user/logout", url.Values{"csrf_token": {client.csrfToken("/snippet/create")}})
+	assert.Equal(t, res.StatusCode, http.StatusSeeOther)
+	assert.Equal(t, res.Header.Get("Location"), "/")
+
+	_, body := client.get("/")
+	assert.StringContains(t, body, "You&#39;ve been logged out successfully!")
+
+	res, _ = client.get("/snippet/create")
+	assert.Equal(t, res.StatusCode, http.StatusSeeOther)
+	assert.Equal(t, res.Header.Get("Location"), "/user/login")
+
+	// The session token changed on logout, so the old cookie is no use.
+	other := newTestClient(t, ts)
+	other.client.Jar.SetCookies(u, stolen)
+	res, _ = other.get("/snippet/create")
+	assert.Equal(t, res.StatusCode, http.StatusSeeOther)
+}
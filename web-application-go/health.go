@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"web-application.antoine.example/internal/health"
+	"web-application.antoine.example/internal/models"
+)
+
+// healthCheckTimeout is how long each readiness check gets before it counts
+// as failed. Orchestrators typically give up on a probe after a second or
+// two, so an answer has to come back sooner than that.
+const healthCheckTimeout = time.Second
+
+var errDraining = errors.New("server is shutting down")
+
+// newReadinessChecker returns the checks behind /readyz. Each check covers
+// something without which the server can't do its job, so a load balancer
+// should stop sending it traffic. Liveness (/healthz) deliberately has no
+// checks: it only shows that the process is answering, since restarting it
+// wouldn't fix a full disk.
+func (app *application) newReadinessChecker(snippets *models.FileSnippetModel, users *models.FileUserModel) *health.Checker {
+	checker := health.New(healthCheckTimeout, app.logger)
+	checker.Add("snippet_storage", snippets.CheckWritable)
+	checker.Add("user_storage", users.CheckWritable)
+	checker.Add("workers", app.workers.check)
+	checker.Add("draining", app.checkDraining)
+	return checker
+}
+
+// checkDraining fails once graceful shutdown has begun, so the server is
+// taken out of rotation while it finishes the requests it already has.
+func (app *application) checkDraining(context.Context) error {
+	if app.draining.Load() {
+		return errDraining
+	}
+	return nil
+}
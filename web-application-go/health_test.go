@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// TestReadinessDraining checks that /readyz is only served on the admin
+// listener, and fails once graceful shutdown has begun.
+func TestReadinessDraining(t *testing.T) {
+	app, routes := newTestApplication(t)
+	app.readiness.Add("draining", app.checkDraining)
+	admin := app.adminRoutes()
+
+	get := func(h http.Handler, path string) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+
+	assert.Equal(t, get(routes, "/readyz"), http.StatusNotFound)
+	assert.Equal(t, get(routes, "/healthz"), http.StatusOK)
+
+	assert.Equal(t, get(admin, "/readyz"), http.StatusOK)
+	app.draining.Store(true)
+	assert.Equal(t, get(admin, "/readyz"), http.StatusServiceUnavailable)
+
+	// Liveness is unaffected: the process is still answering.
+	assert.Equal(t, get(admin, "/healthz"), http.StatusOK)
+}
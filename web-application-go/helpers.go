@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// The serverError helper logs the error along with the request method, URI
+// and a stack trace, then sends a generic 500 Internal Server Error response
+// to the user.
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Error(err.Error(),
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+		"trace", string(debug.Stack()),
+	)
+
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// The clientError helper sends a specific status code and corresponding
+// description to the user, for example 400 "Bad Request".
+func (app *application) clientError(w http.ResponseWriter, status int) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+// The notFound helper is the single place where we send a 404 Not Found
+// response, whether the URL didn't match anything or a record is missing.
+func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
+
+// newTemplateData returns a templateData struct with the fields that every
+// page needs already filled in.
+func (app *application) newTemplateData(r *http.Request) templateData {
+	return templateData{
+		CurrentYear:     time.Now().Year(),
+		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated: app.isAuthenticated(r),
+		CSRFToken:       app.csrfToken(r),
+	}
+}
+
+// render executes the named page from the template cache. The template is
+// written into a buffer first: if it fails half-way we can still send a clean
+// 500, instead of a 200 followed by a truncated page.
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
+	ts, ok := app.templateCache[page]
+	if !ok {
+		err := fmt.Errorf("the template %s does not exist", page)
+		app.serverError(w, r, err)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+
+	err := ts.ExecuteTemplate(buf, "base", data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// isAuthenticated reports whether the request comes from a logged-in user, as
+// decided by the authenticate middleware.
+func (app *application) isAuthenticated(r *http.Request) bool {
+	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
+	if !ok {
+		return false
+	}
+	return isAuthenticated
+}
+
+// safeRedirectPath returns path if it is a local path on this site, or
+// fallback otherwise. It stops a stored redirect from sending users to
+// another host (for example "//evil.example").
+func safeRedirectPath(path, fallback string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return fallback
+	}
+	return path
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+func TestSafeRedirectPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"Local path", "/snippet/view/1", "/snippet/view/1"},
+		{"Local path with query", "/search?q=go&page=2", "/search?q=go&page=2"},
+		{"Root", "/", "/"},
+		{"Empty", "", "/fallback"},
+		{"Protocol-relative", "//evil.com", "/fallback"},
+		{"Protocol-relative with path", "//evil.com/snippet/view/1", "/fallback"},
+		{"Backslash", `/\evil.com`, "/fallback"},
+		{"Absolute URL", "https://evil.com", "/fallback"},
+		{"Absolute URL with path", "https://evil.com/", "/fallback"},
+		{"Scheme only", "javascript:alert(1)", "/fallback"},
+		{"Relative path", "snippet/view/1", "/fallback"},
+		{"Dot relative path", "../snippet/view/1", "/fallback"},
+		{"Host-looking relative path", "evil.com", "/fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, safeRedirectPath(tt.path, "/fallback"), tt.want)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Package assert has the small set of checks shared by the tests. A failed
+// check marks the test as failed but lets it carry on, so one run reports
+// every mismatch.
+package assert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Equal checks that actual and expected are the same.
+func Equal[T comparable](t *testing.T, actual, expected T) {
+	t.Helper()
+
+	if actual != expected {
+		t.Errorf("got: %v; want: %v", actual, expected)
+	}
+}
+
+// StringContains checks that actual contains expectedSubstring.
+func StringContains(t *testing.T, actual, expectedSubstring string) {
+	t.Helper()
+
+	if !strings.Contains(actual, expectedSubstring) {
+		t.Errorf("got: %q; expected to contain: %q", actual, expectedSubstring)
+	}
+}
+
+// NilError checks that actual is nil.
+func NilError(t *testing.T, actual error) {
+	t.Helper()
+
+	if actual != nil {
+		t.Errorf("got: %v; expected: nil", actual)
+	}
+}
+
+// ErrorIs checks that errors.Is(actual, target) holds.
+func ErrorIs(t *testing.T, actual, target error) {
+	t.Helper()
+
+	if !errors.Is(actual, target) {
+		t.Errorf("got: %v; want: %v", actual, target)
+	}
+}
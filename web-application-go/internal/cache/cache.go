@@ -0,0 +1,217 @@
+// Package cache provides a read-through cache with a time to live, a size
+// bound with least-recently-used eviction, and coalescing of concurrent
+// misses.
+//
+// Callers only ever ask for a key; on a miss the cache calls its loader
+// itself. When many callers miss on the same key at once, only the first one
+// runs the loader and the others wait for its result, so a popular entry
+// expiring doesn't send a burst of identical loads to the storage behind it.
+//
+// Every hit moves its entry to the front of the LRU list, so reads take the
+// same lock as writes and a plain Mutex is used. Expired entries are not
+// swept by a goroutine: they are dropped when they are next looked up, or
+// pushed out by newer ones.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options configure a Cache.
+type Options[K comparable, V any] struct {
+	// TTL is how long a loaded value is used before it is loaded again.
+	TTL time.Duration
+	// MaxSize bounds the total size of the cached values, as measured by
+	// Size.
+	MaxSize int
+	// Size returns the size of a value, typically an estimate of the memory
+	// it uses. If it is nil every value has size 1, so MaxSize is a number
+	// of entries.
+	Size func(V) int
+	// Load fetches the value for a key on a miss. Errors are returned to
+	// the caller and not cached.
+	Load func(K) (V, error)
+}
+
+// Stats are counters describing how well a cache is doing.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Size      int
+}
+
+// Cache is a read-through cache. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	opts Options[K, V]
+
+	mu    sync.Mutex
+	lru   *list.List // of *entry[K, V], most recently used first
+	items map[K]*list.Element
+	calls map[K]*call[V]
+	size  int
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	size    int
+	expires time.Time
+}
+
+// call is a load in progress. Goroutines which miss on the same key wait on
+// done and share the result.
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// New returns an empty cache.
+func New[K comparable, V any](opts Options[K, V]) *Cache[K, V] {
+	if opts.Size == nil {
+		opts.Size = func(V) int { return 1 }
+	}
+	return &Cache[K, V]{
+		opts:  opts,
+		lru:   list.New(),
+		items: make(map[K]*list.Element),
+		calls: make(map[K]*call[V]),
+	}
+}
+
+// Get returns the value for key, loading it if it isn't cached or has
+// expired.
+func (c *Cache[K, V]) Get(key K) (V, error) {
+	c.mu.Lock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		if time.Now().Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			return e.value, nil
+		}
+		c.remove(el)
+	}
+
+	c.misses.Add(1)
+
+	// Someone is already loading this key, so wait for them.
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.value, cl.err
+	}
+
+	cl := &call[V]{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	c.load(key, cl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// If the key was invalidated while it was loading, the call has been
+	// forgotten and the value may be stale, so don't keep it.
+	if c.calls[key] == cl {
+		delete(c.calls, key)
+		if cl.err == nil {
+			c.add(key, cl.value)
+		}
+	}
+
+	return cl.value, cl.err
+}
+
+// load calls the loader for a call. It runs without the lock held, so a slow
+// load only holds up the callers waiting for the same key.
+func (c *Cache[K, V]) load(key K, cl *call[V]) {
+	defer func() {
+		// If the loader panics, the waiting callers get an error rather
+		// than waiting forever, and the panic carries on up the stack.
+		if pv := recover(); pv != nil {
+			cl.err = fmt.Errorf("cache: load panicked: %v", pv)
+			c.mu.Lock()
+			if c.calls[key] == cl {
+				delete(c.calls, key)
+			}
+			c.mu.Unlock()
+			close(cl.done)
+			panic(pv)
+		}
+	}()
+
+	cl.value, cl.err = c.opts.Load(key)
+	close(cl.done)
+}
+
+// add stores a value, evicting the least recently used entries to make
+// room. The caller must hold c.mu.
+func (c *Cache[K, V]) add(key K, value V) {
+	size := c.opts.Size(value)
+	if size > c.opts.MaxSize {
+		// It would push everything else out and still not fit.
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	e := &entry[K, V]{key: key, value: value, size: size, expires: time.Now().Add(c.opts.TTL)}
+	c.items[key] = c.lru.PushFront(e)
+	c.size += size
+
+	for c.size > c.opts.MaxSize {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove drops an entry. The caller must hold c.mu.
+func (c *Cache[K, V]) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry[K, V])
+	delete(c.items, e.key)
+	c.size -= e.size
+}
+
+// Invalidate drops the value for key, so the next Get loads it again. It
+// must be called after the underlying data changes. A load of key which is
+// in progress is forgotten too: its callers still get its result, but it
+// won't be cached.
+func (c *Cache[K, V]) Invalidate(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	delete(c.calls, key)
+}
+
+// Stats returns the cache's counters.
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	entries, size := len(c.items), c.size
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+		Size:      size,
+	}
+}
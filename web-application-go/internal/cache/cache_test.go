@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// The tests which depend on time or on goroutines blocking run in a synctest
+// bubble: its clock only moves when every goroutine is blocked, so sleeping
+// past a TTL is instant and exact, and synctest.Wait returns once the
+// goroutines started by a test are all waiting.
+
+// countingLoader returns a Load function which returns key itself as the
+// value and counts its calls.
+func countingLoader(loads *atomic.Int32) func(string) (string, error) {
+	return func(key string) (string, error) {
+		loads.Add(1)
+		return key, nil
+	}
+}
+
+func TestTTL(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var loads atomic.Int32
+		c := New(Options[string, string]{TTL: time.Minute, MaxSize: 10, Load: countingLoader(&loads)})
+
+		for range 3 {
+			v, err := c.Get("a")
+			assert.NilError(t, err)
+			assert.Equal(t, v, "a")
+		}
+		assert.Equal(t, loads.Load(), int32(1))
+
+		time.Sleep(time.Minute - time.Nanosecond)
+		c.Get("a")
+		assert.Equal(t, loads.Load(), int32(1))
+
+		time.Sleep(time.Nanosecond)
+		c.Get("a")
+		assert.Equal(t, loads.Load(), int32(2))
+
+		stats := c.Stats()
+		assert.Equal(t, stats.Hits, uint64(3))
+		assert.Equal(t, stats.Misses, uint64(2))
+	})
+}
+
+func TestLRUEviction(t *testing.T) {
+	var loads atomic.Int32
+	c := New(Options[string, string]{
+		TTL:     time.Hour,
+		MaxSize: 10,
+		Size:    func(v string) int { return len(v) },
+		Load:    countingLoader(&loads),
+	})
+
+	c.Get("aaaa")
+	c.Get("bbbb")
+	c.Get("aaaa") // now more recently used than bbbb
+	c.Get("cccc") // doesn't fit alongside both, so bbbb goes
+
+	stats := c.Stats()
+	assert.Equal(t, stats.Evictions, uint64(1))
+	assert.Equal(t, stats.Entries, 2)
+	assert.Equal(t, stats.Size, 8)
+
+	loads.Store(0)
+	c.Get("aaaa")
+	c.Get("cccc")
+	assert.Equal(t, loads.Load(), int32(0))
+	c.Get("bbbb")
+	assert.Equal(t, loads.Load(), int32(1))
+}
+
+func TestTooLargeNotCached(t *testing.T) {
+	var loads atomic.Int32
+	c := New(Options[string, string]{
+		TTL:     time.Hour,
+		MaxSize: 10,
+		Size:    func(v string) int { return len(v) },
+		Load:    countingLoader(&loads),
+	})
+
+	c.Get("aaaa")
+	big := strings.Repeat("x", 11)
+	v, err := c.Get(big)
+	assert.NilError(t, err)
+	assert.Equal(t, v, big)
+	c.Get(big)
+
+	// The value too big to cache was loaded each time, and didn't push out
+	// what was already there.
+	assert.Equal(t, loads.Load(), int32(3))
+	assert.Equal(t, c.Stats().Entries, 1)
+	assert.Equal(t, c.Stats().Evictions, uint64(0))
+}
+
+func TestErrorsNotCached(t *testing.T) {
+	errLoad := errors.New("load failed")
+	var loads atomic.Int32
+	c := New(Options[string, string]{
+		TTL:     time.Hour,
+		MaxSize: 10,
+		Load: func(string) (string, error) {
+			loads.Add(1)
+			return "", errLoad
+		},
+	})
+
+	_, err := c.Get("a")
+	assert.ErrorIs(t, err, errLoad)
+	_, err = c.Get("a")
+	assert.ErrorIs(t, err, errLoad)
+	assert.Equal(t, loads.Load(), int32(2))
+	assert.Equal(t, c.Stats().Entries, 0)
+}
+
+func TestCoalescedMisses(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var loads atomic.Int32
+		release := make(chan struct{})
+		c := New(Options[string, string]{
+			TTL:     time.Hour,
+			MaxSize: 10,
+			Load: func(key string) (string, error) {
+				loads.Add(1)
+				<-release
+				return key, nil
+			},
+		})
+
+		const callers = 20
+		var wg sync.WaitGroup
+		results := make([]string, callers)
+		for i := range callers {
+			wg.Go(func() {
+				v, err := c.Get("a")
+				assert.NilError(t, err)
+				results[i] = v
+			})
+		}
+
+		// Every caller is now either in the loader or waiting for it.
+		synctest.Wait()
+		assert.Equal(t, loads.Load(), int32(1))
+
+		close(release)
+		wg.Wait()
+
+		for _, v := range results {
+			assert.Equal(t, v, "a")
+		}
+		assert.Equal(t, c.Stats().Misses, uint64(callers))
+
+		c.Get("a")
+		assert.Equal(t, loads.Load(), int32(1))
+	})
+}
+
+func TestInvalidateDuringLoad(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var version atomic.Int32
+		release := make(chan struct{})
+		c := New(Options[string, int32]{
+			TTL:     time.Hour,
+			MaxSize: 10,
+			Load: func(string) (int32, error) {
+				v := version.Load()
+				if v == 1 {
+					<-release
+				}
+				return v, nil
+			},
+		})
+
+		// Start loading version 1, then change the data and invalidate
+		// while the load is still running.
+		version.Store(1)
+		var stale int32
+		done := make(chan struct{})
+		go func() {
+			stale, _ = c.Get("a")
+			close(done)
+		}()
+		synctest.Wait()
+
+		version.Store(2)
+		c.Invalidate("a")
+		close(release)
+		<-done
+
+		// The caller of the interrupted load still gets its result...
+		assert.Equal(t, stale, int32(1))
+
+		// ...but it wasn't cached, so the next Get sees the new data.
+		v, err := c.Get("a")
+		assert.NilError(t, err)
+		assert.Equal(t, v, int32(2))
+	})
+}
+
+func TestInvalidate(t *testing.T) {
+	var loads atomic.Int32
+	c := New(Options[string, string]{TTL: time.Hour, MaxSize: 10, Load: countingLoader(&loads)})
+
+	c.Get("a")
+	c.Invalidate("a")
+	c.Invalidate("never loaded")
+	c.Get("a")
+
+	assert.Equal(t, loads.Load(), int32(2))
+	assert.Equal(t, c.Stats().Entries, 1)
+}
+
+func TestLoaderPanic(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		release := make(chan struct{})
+		c := New(Options[string, string]{
+			TTL:     time.Hour,
+			MaxSize: 10,
+			Load: func(string) (string, error) {
+				<-release
+				panic("boom")
+			},
+		})
+
+		// The caller running the loader sees the panic.
+		panicked := make(chan any)
+		go func() {
+			defer func() { panicked <- recover() }()
+			c.Get("a")
+		}()
+		synctest.Wait()
+
+		// A caller waiting for that load gets an error instead of hanging.
+		waitErr := make(chan error)
+		go func() {
+			_, err := c.Get("a")
+			waitErr <- err
+		}()
+		synctest.Wait()
+
+		close(release)
+		assert.Equal(t, <-panicked, any("boom"))
+		err := <-waitErr
+		if err == nil {
+			t.Fatal("got no error from the waiting caller")
+		}
+		assert.StringContains(t, err.Error(), "load panicked")
+	})
+}
@@ -0,0 +1,204 @@
+// Package diff compares two texts line by line and formats the result as a
+// unified diff, the format produced by diff -u and git diff.
+//
+// Lines are matched with the patience algorithm: lines which occur exactly
+// once in both texts (typically the distinctive ones, rather than blank
+// lines and closing braces) are used as anchors, and the gaps between them
+// are diffed recursively. Gaps with no such lines fall back to a longest
+// common subsequence. The result is not always the shortest possible diff,
+// but it tends to be the one a person would expect, and it is quick even for
+// large inputs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op says what happened to a line.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+func (op Op) String() string {
+	switch op {
+	case Equal:
+		return "equal"
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Line is one line of a diff. OldLine and NewLine are its 1-based line
+// numbers in the old and new texts, or 0 for a line which isn't in that text.
+type Line struct {
+	Op      Op
+	Text    string
+	OldLine int
+	NewLine int
+	// NoNewline is set on the last line of a text which doesn't end with a
+	// newline.
+	NoNewline bool
+}
+
+// Hunk is a group of changed lines with some unchanged lines around them
+// for context.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header returns the hunk's "@@ -1,4 +1,5 @@" line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// hunkRange formats a start and count the way diff -u does: the count is left
+// out when it is 1, and an empty range starts at the line before it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Hunks compares old and new and returns the changes, each with up to
+// context unchanged lines around it. Changes which are close enough for
+// their context to overlap share a hunk. Identical texts have no hunks.
+func Hunks(old, new string, context int) []Hunk {
+	lines := Compare(old, new)
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		// Extend the hunk over every change whose leading context would
+		// touch this one's trailing context.
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Op != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		end = min(len(lines), end+context+1)
+
+		hunks = append(hunks, newHunk(lines, start, end))
+		i = end
+	}
+	return hunks
+}
+
+func newHunk(lines []Line, start, end int) Hunk {
+	h := Hunk{Lines: lines[start:end]}
+
+	// Count the lines of each text before the hunk to find where it starts.
+	for _, l := range lines[:start] {
+		if l.Op != Insert {
+			h.OldStart++
+		}
+		if l.Op != Delete {
+			h.NewStart++
+		}
+	}
+	h.OldStart++
+	h.NewStart++
+
+	for _, l := range h.Lines {
+		if l.Op != Insert {
+			h.OldLines++
+		}
+		if l.Op != Delete {
+			h.NewLines++
+		}
+	}
+	return h
+}
+
+// Unified returns the unified diff between old and new, with three lines of
+// context and the given names in the header. It is empty if the texts are
+// identical.
+func Unified(oldName, newName, old, new string) string {
+	hunks := Hunks(old, new, 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		b.WriteString(h.Header())
+		b.WriteByte('\n')
+		for _, l := range h.Lines {
+			b.WriteByte(" -+"[l.Op])
+			b.WriteString(l.Text)
+			b.WriteByte('\n')
+			if l.NoNewline {
+				b.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// Compare returns every line of old and new, in order, marked as unchanged,
+// deleted or inserted.
+func Compare(old, new string) []Line {
+	a, b := splitLines(old), splitLines(new)
+	ops := diff(a, b, nil)
+
+	lines := make([]Line, 0, len(ops))
+	var i, j int
+	for _, op := range ops {
+		var l Line
+		switch op {
+		case Equal:
+			l = Line{Op: Equal, Text: a[i], OldLine: i + 1, NewLine: j + 1}
+			i++
+			j++
+		case Delete:
+			l = Line{Op: Delete, Text: a[i], OldLine: i + 1}
+			i++
+		case Insert:
+			l = Line{Op: Insert, Text: b[j], NewLine: j + 1}
+			j++
+		}
+
+		// Lines keep their newline while they are compared, so that a
+		// last line without one doesn't match the same text with one. It
+		// is only dropped now, for display.
+		if text, ok := strings.CutSuffix(l.Text, "\n"); ok {
+			l.Text = text
+		} else {
+			l.NoNewline = true
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// splitLines splits s after each newline, so every line but possibly the last
+// keeps its "\n".
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
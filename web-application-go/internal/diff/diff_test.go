@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// TestUnifiedGolden compares Unified with the output of GNU diff for each
+// pair of files in testdata. The .diff files were made with
+//
+//	diff -u --label old --label new NAME.old NAME.new > NAME.diff
+//
+// so they must not be regenerated from Unified itself.
+func TestUnifiedGolden(t *testing.T) {
+	olds, err := filepath.Glob(filepath.Join("testdata", "*.old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(olds) == 0 {
+		t.Fatal("no test cases in testdata")
+	}
+
+	read := func(t *testing.T, path string) string {
+		t.Helper()
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	for _, oldPath := range olds {
+		name := strings.TrimSuffix(oldPath, ".old")
+
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			old := read(t, oldPath)
+			new := read(t, name+".new")
+			want := read(t, name+".diff")
+
+			got := Unified("old", "new", old, new)
+			if got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestUnifiedIdentical(t *testing.T) {
+	assert.Equal(t, Unified("old", "new", "a\nb\n", "a\nb\n"), "")
+	assert.Equal(t, Unified("old", "new", "", ""), "")
+}
+
+func TestHunksContext(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	new := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+
+	tests := []struct {
+		name       string
+		context    int
+		wantHeader string
+		wantLines  int
+	}{
+		{"No context", 0, "@@ -5 +5 @@", 2},
+		{"One line", 1, "@@ -4,3 +4,3 @@", 4},
+		{"More than the text", 10, "@@ -1,9 +1,9 @@", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := Hunks(old, new, tt.context)
+			if len(hunks) != 1 {
+				t.Fatalf("got %d hunks; want 1", len(hunks))
+			}
+			assert.Equal(t, hunks[0].Header(), tt.wantHeader)
+			assert.Equal(t, len(hunks[0].Lines), tt.wantLines)
+		})
+	}
+}
+
+func TestCompareLineNumbers(t *testing.T) {
+	lines := Compare("a\nb\nc", "a\nx\nc")
+
+	want := []Line{
+		{Op: Equal, Text: "a", OldLine: 1, NewLine: 1},
+		{Op: Delete, Text: "b", OldLine: 2},
+		{Op: Insert, Text: "x", NewLine: 2},
+		{Op: Equal, Text: "c", OldLine: 3, NewLine: 3, NoNewline: true},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines; want %d", len(lines), len(want))
+	}
+	for i := range want {
+		assert.Equal(t, lines[i], want[i])
+	}
+}
+
+// TestLargeGap checks that a gap too big for the LCS table, with no unique
+// lines to anchor on, still gives a correct (if not minimal) diff.
+func TestLargeGap(t *testing.T) {
+	var old, new strings.Builder
+	for i := range 1100 {
+		old.WriteString("same\n")
+		if i%2 == 0 {
+			new.WriteString("same\n")
+		} else {
+			new.WriteString("other\n")
+		}
+	}
+
+	var gotOld, gotNew strings.Builder
+	for _, l := range Compare(old.String(), new.String()) {
+		if l.Op != Insert {
+			gotOld.WriteString(l.Text + "\n")
+		}
+		if l.Op != Delete {
+			gotNew.WriteString(l.Text + "\n")
+		}
+	}
+	assert.Equal(t, gotOld.String(), old.String())
+	assert.Equal(t, gotNew.String(), new.String())
+}
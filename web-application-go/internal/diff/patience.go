@@ -0,0 +1,171 @@
+package diff
+
+import "sort"
+
+// maxLCSCells bounds the table used by lcs, to about 4MB. Gaps bigger than
+// that are shown as deleted and re-inserted as a whole.
+const maxLCSCells = 1 << 20
+
+// diff appends to ops the edits which turn a into b, one per line.
+func diff(a, b []string, ops []Op) []Op {
+	// Lines at the start and end which haven't changed need no further
+	// work, and removing them often leaves nothing to compare.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops = repeat(ops, Equal, prefix)
+	ops = patience(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], ops)
+	return repeat(ops, Equal, suffix)
+}
+
+// patience diffs a and b, whose first and last lines differ, by matching up
+// the lines which occur exactly once in each and diffing the gaps between
+// them.
+func patience(a, b []string, ops []Op) []Op {
+	switch {
+	case len(a) == 0:
+		return repeat(ops, Insert, len(b))
+	case len(b) == 0:
+		return repeat(ops, Delete, len(a))
+	}
+
+	anchors := uniqueAnchors(a, b)
+	if len(anchors) == 0 {
+		return lcs(a, b, ops)
+	}
+
+	var i, j int
+	for _, anchor := range anchors {
+		ops = diff(a[i:anchor.a], b[j:anchor.b], ops)
+		ops = append(ops, Equal)
+		i, j = anchor.a+1, anchor.b+1
+	}
+	return diff(a[i:], b[j:], ops)
+}
+
+// anchor is a line which occurs once in each text: at a[a] and b[b].
+type anchor struct {
+	a, b int
+}
+
+// uniqueAnchors returns the longest list of lines unique to both a and b
+// which are in the same order in each.
+func uniqueAnchors(a, b []string) []anchor {
+	type counts struct {
+		a, b   int
+		aIndex int
+		bIndex int
+	}
+	seen := make(map[string]*counts)
+	for i, line := range a {
+		c := seen[line]
+		if c == nil {
+			c = &counts{}
+			seen[line] = c
+		}
+		c.a++
+		c.aIndex = i
+	}
+	for i, line := range b {
+		if c := seen[line]; c != nil {
+			c.b++
+			c.bIndex = i
+		}
+	}
+
+	var unique []anchor
+	for _, c := range seen {
+		if c.a == 1 && c.b == 1 {
+			unique = append(unique, anchor{c.aIndex, c.bIndex})
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].a < unique[j].a })
+
+	return longestIncreasing(unique)
+}
+
+// longestIncreasing returns the longest subsequence of anchors (which are
+// sorted by a) whose b is increasing too, using patience sorting: each anchor
+// goes on the leftmost pile whose top has a greater b, and remembers the top
+// of the pile to its left.
+func longestIncreasing(anchors []anchor) []anchor {
+	if len(anchors) == 0 {
+		return nil
+	}
+
+	tops := []int{}                   // index into anchors of each pile's top
+	prev := make([]int, len(anchors)) // the top of the pile to the left when each was placed
+	for i, an := range anchors {
+		pile := sort.Search(len(tops), func(p int) bool { return anchors[tops[p]].b > an.b })
+		if pile > 0 {
+			prev[i] = tops[pile-1]
+		} else {
+			prev[i] = -1
+		}
+		if pile == len(tops) {
+			tops = append(tops, i)
+		} else {
+			tops[pile] = i
+		}
+	}
+
+	result := make([]anchor, len(tops))
+	for i, k := len(tops)-1, tops[len(tops)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = anchors[k]
+	}
+	return result
+}
+
+// lcs diffs a and b with a longest common subsequence table. It is used for
+// gaps with no unique lines to anchor on, which are usually small.
+func lcs(a, b []string, ops []Op) []Op {
+	if len(a)*len(b) > maxLCSCells {
+		ops = repeat(ops, Delete, len(a))
+		return repeat(ops, Insert, len(b))
+	}
+
+	// table[i*w+j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	w := len(b) + 1
+	table := make([]int32, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i*w+j] = table[(i+1)*w+j+1] + 1
+			} else {
+				table[i*w+j] = max(table[(i+1)*w+j], table[i*w+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Equal)
+			i++
+			j++
+		case table[(i+1)*w+j] >= table[i*w+j+1]:
+			ops = append(ops, Delete)
+			i++
+		default:
+			ops = append(ops, Insert)
+			j++
+		}
+	}
+	ops = repeat(ops, Delete, len(a)-i)
+	return repeat(ops, Insert, len(b)-j)
+}
+
+func repeat(ops []Op, op Op, n int) []Op {
+	for range n {
+		ops = append(ops, op)
+	}
+	return ops
+}
@@ -0,0 +1,168 @@
+// Package health runs a set of named checks, such as "is the storage
+// writable?", and reports their results as JSON for liveness and readiness
+// probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether one dependency is usable, returning nil if it is.
+// It must give up and return soon after ctx is done.
+type Check func(ctx context.Context) error
+
+// Status values used in reports.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker holds a set of checks. Checks are added at startup, before the
+// checker is used; running it is safe for concurrent use.
+type Checker struct {
+	timeout time.Duration
+	logger  *slog.Logger
+	names   []string
+	checks  []Check
+
+	// running is set for each check while a call to it is in progress.
+	running []*atomic.Bool
+}
+
+// New returns a checker with no checks. Each check gets up to timeout to
+// return before it is reported as failed. Failures are logged to logger,
+// with their error, and left out of the report.
+func New(timeout time.Duration, logger *slog.Logger) *Checker {
+	return &Checker{timeout: timeout, logger: logger}
+}
+
+// Add registers a check under a name, which must be unique.
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+	c.running = append(c.running, new(atomic.Bool))
+}
+
+// Result is the outcome of one check. The error is only logged: reports can
+// be read by anyone who can reach the probe, and errors may name files.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Err       error   `json:"-"`
+}
+
+// Report is the outcome of every check. Its status is ok only if every check
+// passed.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+var (
+	// errTimeout is reported for a check which didn't return in time.
+	errTimeout = errors.New("check timed out")
+
+	// errBusy is reported for a check whose previous call still hasn't
+	// returned.
+	errBusy = errors.New("previous check still running")
+)
+
+// Run runs every check at once and waits for them all, or for the timeout.
+// The context passed to the checks is cancelled when the timeout expires.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i := range c.checks {
+		wg.Go(func() {
+			result := c.run(ctx, i)
+			if result.Err != nil {
+				c.logger.Warn("health check failed", "check", c.names[i], "error", result.Err.Error())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.names[i]] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		})
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run runs check i and times it. Probes may arrive faster than a stuck check
+// gives up, so a check which ignores its context can't be started again
+// until its previous call returns; until then it fails straight away, and
+// at most one goroutine per check is ever left waiting on it.
+func (c *Checker) run(ctx context.Context, i int) Result {
+	start := time.Now()
+
+	var err error
+	if c.running[i].CompareAndSwap(false, true) {
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			err := c.checks[i](ctx)
+			c.running[i].Store(false)
+			done <- err
+		}()
+
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = errTimeout
+		}
+	} else {
+		err = errBusy
+	}
+
+	result := Result{
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Err = err
+	}
+	return result
+}
+
+// Handler returns an http.Handler which runs the checks and responds with
+// the report: 200 OK if they all pass, 503 Service Unavailable if not.
+func (c *Checker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		b, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		// Probes must always see the current state, never a cached one.
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(append(b, '\n'))
+	})
+}
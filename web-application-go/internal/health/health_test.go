@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func pass(context.Context) error { return nil }
+
+func TestHandlerStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantStatus int
+		wantReport string
+	}{
+		{"No checks", nil, http.StatusOK, StatusOK},
+		{"All pass", map[string]Check{"a": pass, "b": pass}, http.StatusOK, StatusOK},
+		{
+			name: "One fails",
+			checks: map[string]Check{
+				"a": pass,
+				"b": func(context.Context) error { return errors.New("open /var/lib/secret: permission denied") },
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: StatusFail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(time.Second, discard)
+			for name, check := range tt.checks {
+				c.Add(name, check)
+			}
+
+			rr := httptest.NewRecorder()
+			c.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, rr.Code, tt.wantStatus)
+			assert.Equal(t, rr.Header().Get("Content-Type"), "application/json")
+			assert.Equal(t, rr.Header().Get("Cache-Control"), "no-store")
+
+			body := rr.Body.String()
+			var report struct {
+				Status string
+				Checks map[string]map[string]any
+			}
+			err := json.Unmarshal([]byte(body), &report)
+			assert.NilError(t, err)
+			assert.Equal(t, report.Status, tt.wantReport)
+			assert.Equal(t, len(report.Checks), len(tt.checks))
+
+			// Errors are logged, never sent to the client.
+			for name, result := range report.Checks {
+				if _, ok := result["error"]; ok {
+					t.Errorf("check %s: report includes the error", name)
+				}
+			}
+		})
+	}
+}
+
+// TestRunTimeout checks that a slow check is reported as failed once the
+// timeout passes, and that its context is cancelled so it returns. The
+// synctest bubble fails the test if the check's goroutine is left blocked.
+func TestRunTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		c := New(time.Second, discard)
+		c.Add("fast", pass)
+		c.Add("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		start := time.Now()
+		report := c.Run(context.Background())
+		assert.Equal(t, time.Since(start), time.Second)
+
+		assert.Equal(t, report.Status, StatusFail)
+		assert.Equal(t, report.Checks["fast"].Status, StatusOK)
+		assert.Equal(t, report.Checks["slow"].Status, StatusFail)
+		assert.ErrorIs(t, report.Checks["slow"].Err, errTimeout)
+
+		// The check saw the cancellation and returned.
+		synctest.Wait()
+		assert.Equal(t, c.running[1].Load(), false)
+	})
+}
+
+// TestRunBusy checks that a check which ignores its context is not started
+// again while it is still running.
+func TestRunBusy(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		release := make(chan struct{})
+		var calls atomic.Int32
+		c := New(time.Second, discard)
+		c.Add("stuck", func(context.Context) error {
+			calls.Add(1)
+			<-release
+			return nil
+		})
+
+		report := c.Run(context.Background())
+		assert.ErrorIs(t, report.Checks["stuck"].Err, errTimeout)
+
+		report = c.Run(context.Background())
+		assert.ErrorIs(t, report.Checks["stuck"].Err, errBusy)
+		assert.Equal(t, calls.Load(), int32(1))
+
+		close(release)
+		synctest.Wait()
+
+		report = c.Run(context.Background())
+		assert.Equal(t, report.Status, StatusOK)
+		assert.Equal(t, calls.Load(), int32(2))
+	})
+}
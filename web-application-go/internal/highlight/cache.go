@@ -0,0 +1,69 @@
+package highlight
+
+import (
+	"html/template"
+	"sync"
+)
+
+// Cache remembers the HTML made from recently viewed snippets, so that
+// showing a snippet again doesn't highlight (or otherwise render) it again.
+// Entries are keyed by snippet ID and checked against the language and source
+// they were made from, which means an edited snippet can never be served
+// stale HTML: each revision is rendered once. Deleted snippets are never
+// looked up again, so their entries are simply evicted in time.
+//
+// Cache is safe for concurrent use.
+type Cache struct {
+	render func(lang, src string) template.HTML
+
+	mu      sync.Mutex
+	max     int
+	entries map[int]cacheEntry
+}
+
+type cacheEntry struct {
+	lang string
+	src  string
+	html template.HTML
+}
+
+// NewCache returns a cache of the output of render which holds at most size
+// entries. Highlight is the usual render function.
+func NewCache(size int, render func(lang, src string) template.HTML) *Cache {
+	return &Cache{render: render, max: size, entries: make(map[int]cacheEntry)}
+}
+
+// Render returns the HTML for src in the given language, from the cache if
+// the snippet with the given ID was last rendered with the same language and
+// source.
+func (c *Cache) Render(id int, lang, src string) template.HTML {
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	c.mu.Unlock()
+
+	// Comparing the source is cheap next to rendering it: strings which
+	// share their memory compare equal without reading it.
+	if ok && e.lang == lang && e.src == src {
+		return e.html
+	}
+
+	// Render without holding the lock, so one large snippet doesn't hold up
+	// every other view. Two requests may occasionally both do the work,
+	// which is harmless.
+	html := c.render(lang, src)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[id]; !ok && len(c.entries) >= c.max {
+		// Evict an arbitrary entry; Go's random map order makes this
+		// random eviction, which is good enough for a cache this simple.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[id] = cacheEntry{lang: lang, src: src, html: html}
+
+	return html
+}
@@ -0,0 +1,97 @@
+package highlight
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// clue is a pattern which suggests a language, and how strongly.
+type clue struct {
+	rx     *regexp.Regexp
+	weight int
+}
+
+// clues maps each language to the patterns that suggest it. Detection adds
+// up the weights of the matches (each pattern counts at most three times) and
+// picks the language with the highest score.
+var clues = map[string][]clue{
+	"go": {
+		{regexp.MustCompile(`(?m)^package \w+\s*$`), 6},
+		{regexp.MustCompile(`(?m)^func [\w(]`), 3},
+		{regexp.MustCompile(`(?m)^import \($`), 3},
+		{regexp.MustCompile(`\berr != nil\b`), 3},
+		{regexp.MustCompile(`\w+ :=`), 1},
+		{regexp.MustCompile(`\bfmt\.\w+\(`), 2},
+	},
+	"typescript": {
+		{regexp.MustCompile(`(?m)^import .* from ['"]`), 4},
+		{regexp.MustCompile(`(?m)^export (default |const |function |interface |type |class )`), 3},
+		{regexp.MustCompile(`\binterface \w+ \{`), 2},
+		{regexp.MustCompile(`\w: (string|number|boolean)\b`), 2},
+		{regexp.MustCompile(`\b(const|let) \w+ =`), 1},
+		{regexp.MustCompile(`=>`), 1},
+		{regexp.MustCompile(`\bconsole\.\w+\(`), 2},
+	},
+	"sql": {
+		{regexp.MustCompile(`(?im)^\s*(select|insert into|update|delete from|create (table|index|view)|alter table|drop table)\b`), 4},
+		{regexp.MustCompile(`(?i)\b(from|where|join|group by|order by|values)\b`), 1},
+		{regexp.MustCompile(`(?m);\s*$`), 1},
+	},
+	"bash": {
+		{regexp.MustCompile(`^#!.*\b(ba|z)?sh\b`), 10},
+		{regexp.MustCompile(`(?m)^\s*(echo|cd|export|sudo|apt(-get)?|brew|curl|grep|mkdir|rm|ls|chmod|source|go|npm|git|docker)\s`), 2},
+		{regexp.MustCompile(`\$\{?[A-Za-z_]\w*\}?`), 1},
+		{regexp.MustCompile(`(?m)^\s*(fi|done|esac)\s*$`), 3},
+		{regexp.MustCompile(`(?m)^\s*if \[\[? `), 3},
+		{regexp.MustCompile(`\s(&&|\|\|?)\s`), 1},
+	},
+	"markdown": {
+		{regexp.MustCompile(`(?m)^#{1,6} \S`), 2},
+		{regexp.MustCompile(`(?m)^\s*[-*+] \S`), 1},
+		{regexp.MustCompile(`(?m)^\s*\d+\. \S`), 1},
+		{regexp.MustCompile("(?m)^```"), 3},
+		{regexp.MustCompile(`\[[^\]]+\]\([^)]+\)`), 2},
+		{regexp.MustCompile(`\*\*[^*\n]+\*\*`), 1},
+		{regexp.MustCompile(`(?m)^> `), 2},
+	},
+}
+
+// jsxRX finds JSX elements, which turn TypeScript into TSX: closing tags,
+// self-closing tags and tags with attributes. A bare <Name> could just as well
+// be a type argument.
+var jsxRX = regexp.MustCompile(`</[A-Za-z][\w.]*>|<[A-Za-z][\w.]*(\s[^<>]*)?/>|<[A-Za-z][\w.]*\s+[\w-]+=[{"']`)
+
+// minScore is the lowest score which counts as a detection. Below it the
+// text is treated as plain.
+const minScore = 3
+
+// Detect guesses the language of src and returns its name. It returns Plain
+// if nothing looks likely.
+func Detect(src string) string {
+	trimmed := strings.TrimSpace(src)
+	if trimmed == "" {
+		return Plain
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	best, bestScore := Plain, minScore-1
+	// Go through the languages in a fixed order, so ties always go the same
+	// way.
+	for _, name := range []string{"go", "typescript", "sql", "bash", "markdown"} {
+		score := 0
+		for _, c := range clues[name] {
+			score += c.weight * len(c.rx.FindAllStringIndex(src, 3))
+		}
+		if score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+
+	if best == "typescript" && jsxRX.MatchString(src) {
+		return "tsx"
+	}
+	return best
+}
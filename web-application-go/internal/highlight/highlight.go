@@ -0,0 +1,150 @@
+// Package highlight turns source code into HTML with syntax highlighting. It
+// has small hand-written lexers for the languages people paste most often,
+// and marks tokens up with CSS classes (tok-keyword, tok-string, ...) rather
+// than inline colours, so the look is left entirely to the stylesheet.
+package highlight
+
+import (
+	"html/template"
+	"slices"
+	"strings"
+)
+
+// Language describes a language the package can highlight.
+type Language struct {
+	// Name is the identifier stored with a snippet, such as "go".
+	Name string
+	// Label is the name shown to people, such as "Go".
+	Label string
+	// Extension is the usual file name extension, including the dot.
+	Extension string
+
+	aliases []string
+	lex     func(src string) []token
+}
+
+// token is a run of source text and the class it is highlighted with. An
+// empty class means the text is shown as is.
+type token struct {
+	class string
+	text  string
+}
+
+// Token classes. Each becomes a "tok-" CSS class.
+const (
+	classKeyword  = "keyword"
+	classType     = "type"
+	classBuiltin  = "builtin"
+	classConstant = "constant"
+	classFunction = "function"
+	classString   = "string"
+	classNumber   = "number"
+	classComment  = "comment"
+	classOperator = "operator"
+	classVariable = "variable"
+	classKey      = "key"
+	classTag      = "tag"
+	classAttr     = "attr"
+	classHeading  = "heading"
+	classEmphasis = "emphasis"
+	classStrong   = "strong"
+	classCode     = "code"
+	classLink     = "link"
+	classQuote    = "quote"
+	classMarker   = "marker"
+)
+
+// Plain is the name of the language used for text which shouldn't be
+// highlighted.
+const Plain = "text"
+
+// languages is every supported language, in the order they are offered. It
+// is filled in by init because the Markdown lexer looks languages up itself,
+// to highlight fenced code blocks.
+var languages []*Language
+
+func init() {
+	languages = []*Language{
+		{Name: "go", Label: "Go", Extension: ".go", aliases: []string{"golang"}, lex: goSyntax.lex},
+		{Name: "typescript", Label: "TypeScript", Extension: ".ts", aliases: []string{"ts", "javascript", "js"}, lex: typescriptSyntax.lex},
+		{Name: "tsx", Label: "TSX", Extension: ".tsx", aliases: []string{"jsx"}, lex: tsxSyntax.lex},
+		{Name: "sql", Label: "SQL", Extension: ".sql", aliases: []string{"postgresql", "sqlite"}, lex: sqlSyntax.lex},
+		{Name: "bash", Label: "Bash", Extension: ".sh", aliases: []string{"sh", "shell", "zsh"}, lex: bashSyntax.lex},
+		{Name: "json", Label: "JSON", Extension: ".json", lex: jsonSyntax.lex},
+		{Name: "markdown", Label: "Markdown", Extension: ".md", aliases: []string{"md"}, lex: lexMarkdown},
+		{Name: Plain, Label: "Plain text", Extension: ".txt", aliases: []string{"plain", "txt"}, lex: lexPlain},
+	}
+}
+
+// Languages returns every supported language.
+func Languages() []Language {
+	out := make([]Language, len(languages))
+	for i, l := range languages {
+		out[i] = *l
+	}
+	return out
+}
+
+// Lookup returns the language with the given name, and whether it exists.
+// Common aliases such as "ts" or "sh", as found on Markdown code fences, are
+// accepted too, in any case; the Name of the result is the canonical one.
+func Lookup(name string) (Language, bool) {
+	name = strings.ToLower(name)
+	for _, l := range languages {
+		if l.Name == name || slices.Contains(l.aliases, name) {
+			return *l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Highlight returns src as HTML, highlighted as the named language. If lang
+// is empty the language is detected from src, and an unknown language is
+// treated as plain text. The result is meant to go inside a <pre><code>
+// element.
+func Highlight(lang, src string) template.HTML {
+	if lang == "" {
+		lang = Detect(src)
+	}
+	l, ok := Lookup(lang)
+	if !ok {
+		l, _ = Lookup(Plain)
+	}
+	return render(l.lex(src))
+}
+
+// render escapes each token and wraps the ones with a class in a span.
+func render(tokens []token) template.HTML {
+	var b strings.Builder
+	for _, t := range tokens {
+		if t.class == "" {
+			b.WriteString(template.HTMLEscapeString(t.text))
+			continue
+		}
+		b.WriteString(`<span class="tok-`)
+		b.WriteString(t.class)
+		b.WriteString(`">`)
+		b.WriteString(template.HTMLEscapeString(t.text))
+		b.WriteString(`</span>`)
+	}
+	return template.HTML(b.String())
+}
+
+func lexPlain(src string) []token {
+	return []token{{text: src}}
+}
+
+// tokenList collects tokens, merging neighbours with the same class so the
+// HTML has as few spans as possible.
+type tokenList []token
+
+func (l *tokenList) add(class, text string) {
+	if text == "" {
+		return
+	}
+	if n := len(*l); n > 0 && (*l)[n-1].class == class {
+		(*l)[n-1].text += text
+		return
+	}
+	*l = append(*l, token{class: class, text: text})
+}
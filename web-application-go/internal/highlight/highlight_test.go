@@ -0,0 +1,272 @@
+package highlight
+
+import (
+	"html/template"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// Short names for the token classes, to keep the tables readable.
+func kw(s string) token   { return token{classKeyword, s} }
+func typ(s string) token  { return token{classType, s} }
+func bi(s string) token   { return token{classBuiltin, s} }
+func cst(s string) token  { return token{classConstant, s} }
+func fn(s string) token   { return token{classFunction, s} }
+func str(s string) token  { return token{classString, s} }
+func num(s string) token  { return token{classNumber, s} }
+func com(s string) token  { return token{classComment, s} }
+func op(s string) token   { return token{classOperator, s} }
+func vr(s string) token   { return token{classVariable, s} }
+func key(s string) token  { return token{classKey, s} }
+func tag(s string) token  { return token{classTag, s} }
+func attr(s string) token { return token{classAttr, s} }
+func mk(s string) token   { return token{classMarker, s} }
+func txt(s string) token  { return token{"", s} }
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name string
+		lang string
+		src  string
+		want []token
+	}{
+		{
+			name: "Go keywords, functions and numbers",
+			lang: "go",
+			src:  "func main() { return len(x), 0x1F, 1e-9 }",
+			want: []token{kw("func"), txt(" "), fn("main"), txt("() { "), kw("return"), txt(" "), bi("len"), txt("(x), "), num("0x1F"), txt(", "), num("1e-9"), txt(" }")},
+		},
+		{
+			name: "Go strings with escapes",
+			lang: "go",
+			src:  `f("a\"b", '\'', ` + "`raw\\`" + `)`,
+			want: []token{fn("f"), txt("("), str(`"a\"b"`), txt(", "), str(`'\''`), txt(", "), str("`raw\\`"), txt(")")},
+		},
+		{
+			name: "Go types, constants and comments",
+			lang: "go",
+			src:  "var x error = nil // none\n/* block */",
+			want: []token{kw("var"), txt(" x "), typ("error"), txt(" "), op("="), txt(" "), cst("nil"), txt(" "), com("// none"), txt("\n"), com("/* block */")},
+		},
+		{
+			name: "Unterminated block comment",
+			lang: "go",
+			src:  "x /* never closed\nfunc f()",
+			want: []token{txt("x "), com("/* never closed\nfunc f()")},
+		},
+		{
+			name: "Unterminated string",
+			lang: "go",
+			src:  "s := \"never closed\nfunc",
+			want: []token{txt("s "), op(":="), txt(" "), str("\"never closed\nfunc")},
+		},
+		{
+			name: "Backslash at the end of the source",
+			lang: "go",
+			src:  `s := "a\`,
+			want: []token{txt("s "), op(":="), txt(" "), str(`"a\`)},
+		},
+		{
+			name: "Multi-byte UTF-8",
+			lang: "go",
+			src:  `héllo := "日本\é" // ünï`,
+			want: []token{txt("héllo "), op(":="), txt(" "), str(`"日本\é"`), txt(" "), com("// ünï")},
+		},
+		{
+			name: "TypeScript",
+			lang: "typescript",
+			src:  "const s: string = `a${b}` // c",
+			want: []token{kw("const"), txt(" s"), op(":"), txt(" "), typ("string"), txt(" "), op("="), txt(" "), str("`a${b}`"), txt(" "), com("// c")},
+		},
+		{
+			name: "TypeScript identifiers with $",
+			lang: "ts",
+			src:  "$el = undefined",
+			want: []token{txt("$el "), op("="), txt(" "), cst("undefined")},
+		},
+		{
+			name: "TSX element",
+			lang: "tsx",
+			src:  `return <Foo bar={1} baz="q" />;`,
+			want: []token{kw("return"), txt(" "), tag("<Foo"), txt(" "), attr("bar"), op("="), txt("{"), num("1"), txt("} "), attr("baz"), op("="), str(`"q"`), txt(" "), tag("/>"), txt(";")},
+		},
+		{
+			name: "TSX comparison",
+			lang: "tsx",
+			src:  "a < b && c > d",
+			want: []token{txt("a "), op("<"), txt(" b "), op("&&"), txt(" c "), op(">"), txt(" d")},
+		},
+		{
+			name: "SQL keywords in any case and doubled quotes",
+			lang: "sql",
+			src:  "SELECT count(*) from t WHERE n = 'O''Brien' -- c",
+			want: []token{kw("SELECT"), txt(" "), bi("count"), txt("("), op("*"), txt(") "), kw("from"), txt(" t "), kw("WHERE"), txt(" n "), op("="), txt(" "), str("'O''Brien'"), txt(" "), com("-- c")},
+		},
+		{
+			name: "SQL unterminated comment",
+			lang: "sql",
+			src:  "select /* unterminated",
+			want: []token{kw("select"), txt(" "), com("/* unterminated")},
+		},
+		{
+			name: "Bash variables, strings and comments",
+			lang: "bash",
+			src:  `echo "$HOME" $# ${x} 'raw $x' a#b # c`,
+			want: []token{bi("echo"), txt(" "), str(`"$HOME"`), txt(" "), vr("$#"), txt(" "), vr("${x}"), txt(" "), str("'raw $x'"), txt(" a#b "), com("# c")},
+		},
+		{
+			name: "Bash single quotes have no escapes",
+			lang: "sh",
+			src:  `'a\' b`,
+			want: []token{str(`'a\'`), txt(" b")},
+		},
+		{
+			name: "JSON keys and values",
+			lang: "json",
+			src:  `{"k": "v\"", "n": [1, true, null]}`,
+			want: []token{txt("{"), key(`"k"`), op(":"), txt(" "), str(`"v\""`), txt(", "), key(`"n"`), op(":"), txt(" ["), num("1"), txt(", "), cst("true"), txt(", "), cst("null"), txt("]}")},
+		},
+		{
+			name: "Markdown",
+			lang: "markdown",
+			src:  "# Title\n- *em* **strong** `code` [l](u)\n> quote\n",
+			want: []token{{classHeading, "# Title\n"}, mk("- "), {classEmphasis, "*em*"}, txt(" "), {classStrong, "**strong**"}, txt(" "), {classCode, "`code`"}, txt(" "), {classLink, "[l](u)"}, txt("\n"), {classQuote, "> quote\n"}},
+		},
+		{
+			name: "Markdown fence in a known language",
+			lang: "md",
+			src:  "```go\nvar x int\n```\n",
+			want: []token{mk("```go\n"), kw("var"), txt(" x "), typ("int"), txt("\n"), mk("```\n")},
+		},
+		{
+			name: "Markdown unclosed fence",
+			lang: "markdown",
+			src:  "```\ncode <b>\n",
+			want: []token{mk("```\n"), {classCode, "code <b>\n"}},
+		},
+		{
+			name: "Plain text",
+			lang: "text",
+			src:  "func <b>",
+			want: []token{txt("func <b>")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, ok := Lookup(tt.lang)
+			if !ok {
+				t.Fatalf("unknown language %q", tt.lang)
+			}
+
+			got := l.lex(tt.src)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// spanRX matches the markup render adds around tokens.
+var spanRX = regexp.MustCompile(`<span class="tok-[a-z]+">|</span>`)
+
+// TestHighlightText checks that, in every language, highlighting only adds
+// spans: taking them out leaves exactly the escaped source, so nothing is
+// lost, duplicated or left unescaped.
+func TestHighlightText(t *testing.T) {
+	sources := []string{
+		"",
+		`<script>alert("&")</script>`,
+		"func f() { s := \"a\\\"b\" /* x */ }",
+		"/* unterminated <b>",
+		"\"unterminated & \\",
+		"`unterminated",
+		"return <div a={x > 1}>{y}</div>",
+		"<Foo bar='1'",
+		"echo ${unterminated $ # ' \\",
+		`{"a": [1, 2e10, -3.5]}`,
+		"# é\n- ü *ï* **日本** `語` [🙂](u)\n```go\nx := \"ß\"\n",
+		"a\x00b\xffc\r\n",
+		"日本語 🙂 ünïcödé",
+	}
+
+	for _, l := range Languages() {
+		for _, src := range sources {
+			html := Highlight(l.Name, src)
+			got := spanRX.ReplaceAllString(string(html), "")
+			if got != template.HTMLEscapeString(src) {
+				t.Errorf("%s: text of %q is %q", l.Name, src, got)
+			}
+		}
+	}
+}
+
+func TestHighlightUnknownLanguage(t *testing.T) {
+	assert.Equal(t, Highlight("cobol", "<func>"), template.HTML("&lt;func&gt;"))
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"go", "go", true},
+		{"Golang", "go", true},
+		{"JS", "typescript", true},
+		{"jsx", "tsx", true},
+		{"zsh", "bash", true},
+		{"md", "markdown", true},
+		{"plain", Plain, true},
+		{"cobol", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, ok := Lookup(tt.name)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, l.Name, tt.want)
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Go", "package main\n\nfunc main() {\n\tif err != nil {\n\t}\n}\n", "go"},
+		{"TypeScript", "import { x } from 'y'\nexport const a: string = 'b'\n", "typescript"},
+		{"TSX", "import React from 'react'\nexport default () => <div className=\"x\">hi</div>\n", "tsx"},
+		{"SQL", "SELECT id, name\nFROM users\nWHERE id = 1;\n", "sql"},
+		{"Bash shebang", "#!/bin/bash\nls\n", "bash"},
+		{"Bash commands", "cd /tmp\nif [ -f x ]; then\n  echo hi\nfi\n", "bash"},
+		{"JSON object", `{"a": 1, "b": [true, null]}`, "json"},
+		{"JSON array", ` [1, 2, 3] `, "json"},
+		{"Invalid JSON", `{"a": }`, Plain},
+		{"Markdown", "# Notes\n\n- one\n- two\n\nSee [the docs](https://example.com).\n", "markdown"},
+		{"Empty", "", Plain},
+		{"White space", " \n\t", Plain},
+		{"Prose", "Remember to buy milk and eggs.", Plain},
+		{"Weak clue only", "x := 1", Plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Detect(tt.src), tt.want)
+		})
+	}
+}
+
+// TestHighlightDetects checks that an empty language means detection.
+func TestHighlightDetects(t *testing.T) {
+	html := string(Highlight("", "package main\n\nfunc main() {}\n"))
+	if !strings.Contains(html, `<span class="tok-keyword">package</span>`) {
+		t.Errorf("Go wasn't detected: %s", html)
+	}
+}
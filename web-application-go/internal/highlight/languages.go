@@ -0,0 +1,83 @@
+package highlight
+
+var goSyntax = &syntax{
+	lineComments:  []string{"//"},
+	blockComments: [][2]string{{"/*", "*/"}},
+	quotes:        `"'`,
+	rawQuotes:     "`",
+	keywords: words(`break case chan const continue default defer else fallthrough
+		for func go goto if import interface map package range return select
+		struct switch type var`),
+	types: words(`any bool byte comparable complex64 complex128 error float32
+		float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32
+		uint64 uintptr`),
+	builtins: words(`append cap clear close complex copy delete imag len make max
+		min new panic print println real recover`),
+	constants: words(`true false nil iota`),
+}
+
+var typescriptSyntax = &syntax{
+	lineComments:  []string{"//"},
+	blockComments: [][2]string{{"/*", "*/"}},
+	quotes:        "\"'`",
+	keywords: words(`abstract as async await break case catch class const
+		continue debugger declare default delete do else enum export extends
+		finally for from function if implements import in infer instanceof
+		interface is keyof let namespace new of private protected public
+		readonly return satisfies static super switch this throw try type
+		typeof var void while yield`),
+	types: words(`any bigint boolean never number object string symbol unknown
+		Array Promise Record Partial Readonly`),
+	builtins: words(`console document window globalThis JSON Math Object Number
+		String Boolean Date Error Map Set RegExp Symbol`),
+	constants:  words(`true false null undefined NaN Infinity`),
+	identChars: "$",
+}
+
+// tsxSyntax is TypeScript with JSX elements.
+var tsxSyntax = func() *syntax {
+	s := *typescriptSyntax
+	s.jsx = true
+	return &s
+}()
+
+var sqlSyntax = &syntax{
+	lineComments:  []string{"--"},
+	blockComments: [][2]string{{"/*", "*/"}},
+	quotes:        `"`,
+	rawQuotes:     `'`,
+	keywords: words(`add all alter and as asc begin between by cascade case check
+		column commit conflict constraint create cross default delete desc
+		distinct do drop else end except exists foreign from full group having
+		if in index inner insert intersect into is join key left like limit
+		not nothing offset on or order outer primary references returning
+		right rollback select set table then transaction trigger union unique
+		update using values view when where with`),
+	types: words(`bigint bigserial blob bool boolean bytea char date decimal
+		double float int integer interval json jsonb numeric precision real
+		serial smallint text time timestamp timestamptz uuid varchar`),
+	builtins: words(`avg cast coalesce count length lower max min now nullif
+		substring sum upper`),
+	constants: words(`true false null`),
+	foldCase:  true,
+}
+
+var bashSyntax = &syntax{
+	lineComments: []string{"#"},
+	quotes:       "\"`",
+	rawQuotes:    "'",
+	keywords: words(`case do done elif else esac fi for function if in select
+		then time until while`),
+	builtins: words(`alias break cd continue declare echo eval exec exit export
+		local printf pwd read readonly return set shift source test trap unset
+		wait`),
+	constants:    words(`true false`),
+	wordComments: true,
+	variables:    true,
+}
+
+var jsonSyntax = &syntax{
+	quotes:     `"`,
+	constants:  words(`true false null`),
+	objectKeys: true,
+}
@@ -0,0 +1,358 @@
+package highlight
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// syntax describes a language in the C family closely enough for a simple
+// lexer: its comments, string quotes and word lists. Go, TypeScript, SQL,
+// Bash and JSON are all lexed by syntax.lex with different settings.
+type syntax struct {
+	lineComments  []string
+	blockComments [][2]string
+	// quotes are string delimiters inside which a backslash escapes the
+	// next character, and rawQuotes ones where it doesn't.
+	quotes    string
+	rawQuotes string
+
+	keywords  set
+	types     set
+	builtins  set
+	constants set
+
+	// foldCase makes the word lists case-insensitive (for SQL).
+	foldCase bool
+	// identChars are characters other than letters, digits and underscores
+	// allowed in identifiers.
+	identChars string
+	// wordComments only starts a line comment at the beginning of a word,
+	// so that Bash's "$#" and "a#b" aren't comments.
+	wordComments bool
+	// variables highlights $name and ${name} (for Bash).
+	variables bool
+	// objectKeys highlights strings followed by a colon as keys (for JSON).
+	objectKeys bool
+	// jsx recognises JSX elements (for TSX).
+	jsx bool
+}
+
+// set is a set of words.
+type set map[string]bool
+
+func words(s string) set {
+	m := set{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+const operatorChars = "+-*/%=<>!&|^~?:"
+
+func (sx *syntax) lex(src string) []token {
+	l := &lexer{syntax: sx, src: src}
+	l.run()
+	return l.out
+}
+
+type lexer struct {
+	*syntax
+	src string
+	pos int
+	out tokenList
+
+	// last is the last token which wasn't white space, used to tell a JSX
+	// element from a less-than sign.
+	last token
+	// inTag is set inside a JSX tag, and braces counts the open braces of
+	// an expression in one of its attributes.
+	inTag  bool
+	braces int
+}
+
+func (l *lexer) emit(class string, end int) {
+	text := l.src[l.pos:end]
+	l.out.add(class, text)
+	if strings.TrimSpace(text) != "" {
+		l.last = token{class: class, text: text}
+	}
+	l.pos = end
+}
+
+func (l *lexer) run() {
+	for l.pos < len(l.src) {
+		if l.inTag && l.braces == 0 {
+			l.lexTag()
+			continue
+		}
+
+		rest := l.src[l.pos:]
+		c := rest[0]
+
+		switch {
+		case isSpace(c):
+			end := l.pos + 1
+			for end < len(l.src) && isSpace(l.src[end]) {
+				end++
+			}
+			l.emit("", end)
+		case l.lineComment(rest):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			l.emit(classComment, l.pos+end)
+		case l.blockComment(rest):
+		case strings.IndexByte(l.quotes, c) >= 0:
+			l.lexString(c, true)
+		case strings.IndexByte(l.rawQuotes, c) >= 0:
+			l.lexString(c, false)
+		case isDigit(c) || c == '.' && len(rest) > 1 && isDigit(rest[1]):
+			end := l.pos + 1
+			for end < len(l.src) && (isIdent(l.src[end]) || l.src[end] == '.') {
+				// Allow a sign after an exponent, as in 1e-9.
+				if (l.src[end] == 'e' || l.src[end] == 'E') && end+1 < len(l.src) &&
+					(l.src[end+1] == '-' || l.src[end+1] == '+') && !strings.HasPrefix(rest, "0x") {
+					end++
+				}
+				end++
+			}
+			l.emit(classNumber, end)
+		case l.variables && c == '$':
+			l.lexVariable()
+		case l.jsx && c == '<' && l.tagStart(rest):
+			l.openTag()
+		case l.identStart(rest):
+			l.lexWord()
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			if l.inTag {
+				// Track the braces of an attribute expression, to know
+				// when the lexer is back in the tag.
+				switch c {
+				case '{':
+					l.braces++
+				case '}':
+					l.braces--
+				}
+			}
+			class := ""
+			if strings.IndexByte(operatorChars, c) >= 0 {
+				class = classOperator
+			}
+			l.emit(class, l.pos+size)
+		}
+	}
+}
+
+func (l *lexer) lineComment(rest string) bool {
+	if l.wordComments && l.pos > 0 && !isSpace(l.src[l.pos-1]) {
+		return false
+	}
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(rest, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockComment emits a block comment starting at the current position, if
+// there is one. An unterminated comment runs to the end of the source.
+func (l *lexer) blockComment(rest string) bool {
+	for _, delims := range l.blockComments {
+		if !strings.HasPrefix(rest, delims[0]) {
+			continue
+		}
+		end := strings.Index(rest[len(delims[0]):], delims[1])
+		if end < 0 {
+			l.emit(classComment, len(l.src))
+		} else {
+			l.emit(classComment, l.pos+len(delims[0])+end+len(delims[1]))
+		}
+		return true
+	}
+	return false
+}
+
+// lexString emits a string which starts with the quote at the current
+// position and ends at the next unescaped quote, or at the end of the
+// source if there isn't one.
+func (l *lexer) lexString(quote byte, escapes bool) {
+	end := l.pos + 1
+	for end < len(l.src) {
+		c := l.src[end]
+		end++
+		if c == '\\' && escapes && end < len(l.src) {
+			end++
+		} else if c == quote {
+			break
+		}
+	}
+
+	class := classString
+	if l.objectKeys {
+		rest := strings.TrimLeft(l.src[end:], " \t\r\n")
+		if strings.HasPrefix(rest, ":") {
+			class = classKey
+		}
+	}
+	l.emit(class, end)
+}
+
+// lexVariable emits a shell variable: $name, ${...}, or a special
+// parameter such as $1 or $?.
+func (l *lexer) lexVariable() {
+	end := l.pos + 1
+	switch {
+	case end < len(l.src) && l.src[end] == '{':
+		if i := strings.IndexByte(l.src[end:], '}'); i >= 0 {
+			end += i + 1
+		} else {
+			end = len(l.src)
+		}
+	case end < len(l.src) && strings.IndexByte("0123456789?#@*$!-", l.src[end]) >= 0:
+		end++
+	default:
+		for end < len(l.src) && isIdent(l.src[end]) {
+			end++
+		}
+	}
+	l.emit(classVariable, end)
+}
+
+func (l *lexer) identStart(rest string) bool {
+	r, _ := utf8.DecodeRuneInString(rest)
+	return r == '_' || unicode.IsLetter(r) || strings.ContainsRune(l.identChars, r)
+}
+
+func (l *lexer) identEnd(from int) int {
+	end := from
+	for end < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[end:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(l.identChars, r) {
+			break
+		}
+		end += size
+	}
+	return end
+}
+
+// lexWord emits an identifier, classed by the word lists. Other identifiers
+// followed by an opening parenthesis are taken to be function names.
+func (l *lexer) lexWord() {
+	end := l.identEnd(l.pos)
+
+	word := l.src[l.pos:end]
+	if l.foldCase {
+		word = strings.ToLower(word)
+	}
+
+	class := ""
+	switch {
+	case l.keywords[word]:
+		class = classKeyword
+	case l.types[word]:
+		class = classType
+	case l.constants[word]:
+		class = classConstant
+	case l.builtins[word]:
+		class = classBuiltin
+	case strings.HasPrefix(strings.TrimLeft(l.src[end:], " \t"), "("):
+		class = classFunction
+	}
+	l.emit(class, end)
+}
+
+// tagStart reports whether the '<' at the start of rest opens a JSX
+// element rather than being a comparison or a type argument list. That
+// depends on what came before: after a value, it's an operator.
+func (l *lexer) tagStart(rest string) bool {
+	if len(rest) < 2 {
+		return false
+	}
+	next := rest[1]
+	if next == '/' {
+		return len(rest) > 2 && (isLetter(rest[2]) || rest[2] == '>')
+	}
+	if !isLetter(next) && next != '>' {
+		return false
+	}
+
+	switch l.last.class {
+	case "", classOperator:
+		// Punctuation and operators are followed by a value, while
+		// identifiers and closing brackets end one.
+		if l.last.text == "" {
+			return true
+		}
+		end := l.last.text[len(l.last.text)-1]
+		return !isIdent(end) && end != ')' && end != ']'
+	case classKeyword:
+		return l.last.text == "return" || l.last.text == "default" || l.last.text == "yield"
+	case classTag:
+		return true
+	}
+	return false
+}
+
+// openTag emits the start of a JSX tag ("<div" or "</div") and switches the
+// lexer into tag mode.
+func (l *lexer) openTag() {
+	end := l.pos + 1
+	if l.src[end] == '/' {
+		end++
+	}
+	for end < len(l.src) && (isIdent(l.src[end]) || l.src[end] == '.' || l.src[end] == '-') {
+		end++
+	}
+	l.emit(classTag, end)
+	l.inTag = true
+}
+
+// lexTag lexes one piece of the inside of a JSX tag: an attribute name, a
+// string, the start of an expression, or the end of the tag.
+func (l *lexer) lexTag() {
+	rest := l.src[l.pos:]
+	c := rest[0]
+
+	switch {
+	case strings.HasPrefix(rest, "/>"):
+		l.emit(classTag, l.pos+2)
+		l.inTag = false
+	case c == '>':
+		l.emit(classTag, l.pos+1)
+		l.inTag = false
+	case isSpace(c):
+		end := l.pos + 1
+		for end < len(l.src) && isSpace(l.src[end]) {
+			end++
+		}
+		l.emit("", end)
+	case c == '"' || c == '\'':
+		l.lexString(c, false)
+	case c == '{':
+		l.braces = 1
+		l.emit("", l.pos+1)
+	case isLetter(c) || c == '_':
+		end := l.pos + 1
+		for end < len(l.src) && (isIdent(l.src[end]) || l.src[end] == '-' || l.src[end] == ':') {
+			end++
+		}
+		l.emit(classAttr, end)
+	default:
+		_, size := utf8.DecodeRuneInString(rest)
+		class := ""
+		if c == '=' {
+			class = classOperator
+		}
+		l.emit(class, l.pos+size)
+	}
+}
+
+func isSpace(c byte) bool  { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+func isIdent(c byte) bool  { return isLetter(c) || isDigit(c) || c == '_' }
@@ -0,0 +1,144 @@
+package highlight
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Markdown is lexed a line at a time, since most of its syntax is decided by
+// how a line starts. Fenced code blocks are highlighted as the language named
+// on the fence, when it is one this package knows.
+
+var (
+	headingRX   = regexp.MustCompile(`^ {0,3}#{1,6}(\s|$)`)
+	ruleRX      = regexp.MustCompile(`^ {0,3}((\* *){3,}|(- *){3,}|(_ *){3,})$`)
+	listItemRX  = regexp.MustCompile(`^ *([-*+]|\d{1,9}[.)])( +|$)`)
+	quoteRX     = regexp.MustCompile(`^ {0,3}>`)
+	fenceOpenRX = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)$")
+)
+
+func lexMarkdown(src string) []token {
+	var out tokenList
+	lines := strings.SplitAfter(src, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		body := strings.TrimRight(line, "\r\n")
+
+		if m := fenceOpenRX.FindStringSubmatch(body); m != nil {
+			i = lexFence(&out, lines, i, m[1], m[2])
+			continue
+		}
+
+		switch {
+		case headingRX.MatchString(body):
+			out.add(classHeading, line)
+		case ruleRX.MatchString(body):
+			out.add(classMarker, line)
+		case quoteRX.MatchString(body):
+			out.add(classQuote, line)
+		default:
+			if m := listItemRX.FindString(body); m != "" {
+				out.add(classMarker, m)
+				line = line[len(m):]
+			}
+			lexInline(&out, line)
+		}
+	}
+
+	return out
+}
+
+// lexFence lexes the fenced code block opened on lines[i] and returns the
+// index of its closing line. A block which is never closed runs to the end.
+func lexFence(out *tokenList, lines []string, i int, fence, info string) int {
+	out.add(classMarker, lines[i])
+
+	end := i + 1
+	for end < len(lines) {
+		body := strings.TrimSpace(lines[end])
+		if strings.HasPrefix(body, fence) && strings.Trim(body, fence[:1]) == "" {
+			break
+		}
+		end++
+	}
+
+	code := strings.Join(lines[i+1:end], "")
+	if fields := strings.Fields(info); len(fields) > 0 {
+		if l, ok := Lookup(fields[0]); ok {
+			for _, t := range l.lex(code) {
+				out.add(t.class, t.text)
+			}
+			code = ""
+		}
+	}
+	out.add(classCode, code)
+
+	if end < len(lines) {
+		out.add(classMarker, lines[end])
+	}
+	return end
+}
+
+// lexInline lexes the inline syntax of one line: code spans, emphasis and
+// links.
+func lexInline(out *tokenList, s string) {
+	for s != "" {
+		i := strings.IndexAny(s, "`*_[<")
+		if i < 0 {
+			break
+		}
+		out.add("", s[:i])
+		s = s[i:]
+
+		class, n := inlineSpan(s)
+		if n == 0 {
+			out.add("", s[:1])
+			s = s[1:]
+			continue
+		}
+		out.add(class, s[:n])
+		s = s[n:]
+	}
+	out.add("", s)
+}
+
+// inlineSpan returns the class and length of the inline element at the
+// start of s, or a length of 0 if there isn't one.
+func inlineSpan(s string) (string, int) {
+	switch s[0] {
+	case '`':
+		run := len(s) - len(strings.TrimLeft(s, "`"))
+		if end := strings.Index(s[run:], s[:run]); end >= 0 {
+			return classCode, run + end + run
+		}
+	case '*', '_':
+		double := s[:1] + s[:1]
+		if strings.HasPrefix(s, double) {
+			if end := strings.Index(s[2:], double); end > 0 {
+				return classStrong, 2 + end + 2
+			}
+			return "", 0
+		}
+		// A single underscore is usually part of a word such as
+		// snake_case, so only asterisks count for emphasis.
+		if s[0] == '*' && len(s) > 1 && s[1] != ' ' {
+			if end := strings.IndexByte(s[1:], '*'); end > 0 {
+				return classEmphasis, 1 + end + 1
+			}
+		}
+	case '[':
+		if mid := strings.Index(s, "]("); mid > 0 {
+			if end := strings.IndexByte(s[mid:], ')'); end > 0 {
+				return classLink, mid + end + 1
+			}
+		}
+	case '<':
+		if strings.HasPrefix(s, "<http://") || strings.HasPrefix(s, "<https://") {
+			if end := strings.IndexByte(s, '>'); end > 0 {
+				return classLink, end + 1
+			}
+		}
+	}
+	return "", 0
+}
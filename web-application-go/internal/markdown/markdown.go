@@ -0,0 +1,82 @@
+// Package markdown renders Markdown snippets to HTML which is safe to put in
+// a page. Parsing follows CommonMark, plus the GitHub extensions people expect
+// (tables, strikethrough, task lists and bare links). Fenced code blocks are
+// highlighted with package highlight.
+//
+// The output is sanitised by construction rather than cleaned up afterwards:
+// raw HTML in the source is never passed through, and links or images with
+// dangerous URLs (javascript: and the like) lose their URL.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+
+	"web-application.antoine.example/internal/highlight"
+)
+
+// md is the configured converter. It is safe for concurrent use.
+//
+// goldmark's HTML renderer omits raw HTML and dangerous URLs unless it is
+// given the WithUnsafe option, which must never be added here.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+	),
+)
+
+// Render converts Markdown source to HTML. The lang argument is ignored; it
+// is there so Render can be used with highlight.Cache.
+func Render(lang, src string) template.HTML {
+	var buf bytes.Buffer
+
+	// Converting into a bytes.Buffer can't fail: goldmark only returns
+	// errors from the writer and from renderers, and ours don't fail. Fall
+	// back to the escaped source all the same, rather than show nothing.
+	err := md.Convert([]byte(src), &buf)
+	if err != nil {
+		return template.HTML("<pre>" + template.HTMLEscapeString(src) + "</pre>")
+	}
+
+	return template.HTML(buf.String())
+}
+
+// codeBlockRenderer renders fenced code blocks with syntax highlighting, in
+// the same markup the snippet page uses for code snippets.
+type codeBlockRenderer struct{}
+
+func (r codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	var code strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	// A fence without a language is detected, and one naming a language
+	// the highlighter doesn't know is shown as plain text.
+	lang := string(n.Language(source))
+
+	w.WriteString(`<pre><code class="highlight">`)
+	w.WriteString(string(highlight.Highlight(lang, code.String())))
+	w.WriteString("</code></pre>\n")
+
+	return ast.WalkSkipChildren, nil
+}
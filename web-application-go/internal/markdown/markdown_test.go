@@ -0,0 +1,144 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// TestRenderUnsafe checks that nothing in a snippet can get script into the
+// page: raw HTML is dropped, and links and images with a dangerous URL keep
+// their text but lose the URL. Adding goldmark's WithUnsafe option, or a
+// renderer which passes HTML through, makes these fail.
+func TestRenderUnsafe(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Script block",
+			src:  "<script>alert(1)</script>",
+			want: "<!-- raw HTML omitted -->\n",
+		},
+		{
+			name: "Inline script",
+			src:  "hi <script>alert(1)</script> there",
+			want: "<p>hi <!-- raw HTML omitted -->alert(1)<!-- raw HTML omitted --> there</p>\n",
+		},
+		{
+			name: "Image with onerror",
+			src:  `text <img src=x onerror="alert(1)"> more`,
+			want: "<p>text <!-- raw HTML omitted --> more</p>\n",
+		},
+		{
+			name: "Raw link",
+			src:  `<a href="javascript:alert(1)">x</a>`,
+			want: "<p><!-- raw HTML omitted -->x<!-- raw HTML omitted --></p>\n",
+		},
+		{
+			name: "javascript: link",
+			src:  "[x](javascript:alert(1))",
+			want: `<p><a href="">x</a></p>` + "\n",
+		},
+		{
+			name: "Mixed case javascript: link",
+			src:  "[x](JavaScript:alert(1))",
+			want: `<p><a href="">x</a></p>` + "\n",
+		},
+		{
+			name: "Entity-encoded javascript: link",
+			src:  "[x](&#106;avascript:alert(1))",
+			want: `<p><a href="">x</a></p>` + "\n",
+		},
+		{
+			name: "Reference link",
+			src:  "[x]: javascript:alert(1)\n\n[x]",
+			want: `<p><a href="">x</a></p>` + "\n",
+		},
+		{
+			name: "vbscript: link",
+			src:  "[x](vbscript:msgbox(1))",
+			want: `<p><a href="">x</a></p>` + "\n",
+		},
+		{
+			name: "data: link",
+			src:  "[x](data:text/html;base64,PHNjcmlwdD4=)",
+			want: `<p><a href="">x</a></p>` + "\n",
+		},
+		{
+			name: "javascript: image",
+			src:  "![x](javascript:alert(1))",
+			want: `<p><img src="" alt="x"></p>` + "\n",
+		},
+		{
+			name: "SVG data: image",
+			src:  "![x](data:image/svg+xml;base64,PHN2Zz4=)",
+			want: `<p><img src="" alt="x"></p>` + "\n",
+		},
+		{
+			name: "javascript: autolink",
+			src:  "<javascript:alert(1)>",
+			want: `<p><a href="">javascript:alert(1)</a></p>` + "\n",
+		},
+		{
+			name: "vbscript: autolink",
+			src:  "<vbscript:msgbox(1)>",
+			want: `<p><a href="">vbscript:msgbox(1)</a></p>` + "\n",
+		},
+		{
+			name: "data: autolink",
+			src:  "<data:text/html,hi>",
+			want: `<p><a href="">data:text/html,hi</a></p>` + "\n",
+		},
+		{
+			name: "Bare link followed by a tag",
+			src:  "https://example.com/<script>",
+			want: `<p><a href="https://example.com/">https://example.com/</a><!-- raw HTML omitted --></p>` + "\n",
+		},
+		{
+			name: "Bare link with a quote",
+			src:  `https://example.com/"onmouseover=alert(1)`,
+			want: `<p><a href="https://example.com/%22onmouseover=alert(1)">https://example.com/&quot;onmouseover=alert(1)</a></p>` + "\n",
+		},
+		{
+			name: "Title with a quote",
+			src:  `[x](https://example.com "t\" onmouseover=alert(1)")`,
+			want: `<p><a href="https://example.com" title="t&quot; onmouseover=alert(1)">x</a></p>` + "\n",
+		},
+		{
+			name: "Code span",
+			src:  "`<script>`",
+			want: "<p><code>&lt;script&gt;</code></p>\n",
+		},
+		{
+			name: "Fenced code block",
+			src:  "```\n<script>alert(1)</script>\n```",
+			want: `<pre><code class="highlight">&lt;script&gt;alert(1)&lt;/script&gt;` + "\n</code></pre>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Render("", tt.src))
+			assert.Equal(t, got, tt.want)
+
+			for _, bad := range []string{"<script", "<img src=x", "onerror=", `="javascript:`, `="vbscript:`, `="data:`} {
+				if strings.Contains(strings.ToLower(got), strings.ToLower(bad)) {
+					t.Errorf("output contains %q", bad)
+				}
+			}
+		})
+	}
+}
+
+// TestRenderExtensions checks that the GitHub extensions are enabled.
+func TestRenderExtensions(t *testing.T) {
+	got := string(Render("", "~~old~~ https://example.com\n\n- [x] done\n\n| a |\n|---|\n| b |"))
+
+	assert.StringContains(t, got, "<del>old</del>")
+	assert.StringContains(t, got, `<a href="https://example.com">https://example.com</a>`)
+	assert.StringContains(t, got, `<input checked="" disabled="" type="checkbox"> done`)
+	assert.StringContains(t, got, "<td>b</td>")
+}
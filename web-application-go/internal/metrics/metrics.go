@@ -0,0 +1,238 @@
+// Package metrics is a small, dependency-free implementation of the metric
+// types Prometheus understands (counters, gauges and histograms, with or
+// without labels) and of its text exposition format. It covers what the
+// server needs and nothing more; there are no summaries, no exemplars and no
+// protobuf format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds a set of metrics and writes them out in the order they were
+// registered. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+	hooks   []func()
+}
+
+// metric is implemented by every metric type.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Registering the same name twice is a programming error, so fail
+	// loudly at startup rather than produce an invalid exposition.
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// OnScrape registers fn to run before each scrape, to refresh values which
+// are expensive to read, such as runtime.MemStats, once for several metrics.
+func (r *Registry) OnScrape(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, fn)
+}
+
+// WriteTo writes every metric in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	hooks := slices.Clone(r.hooks)
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler returns an http.Handler which serves the registry's metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// desc is the name, help text and label names shared by every series of a
+// metric.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// writeSample writes one line: name{labels} value. Extra label pairs (such
+// as a histogram's le) come after the metric's own labels.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extra []string, v float64) {
+	w.WriteString(name)
+	if len(labels)+len(extra) > 0 {
+		w.WriteByte('{')
+		sep := ""
+		for i, l := range labels {
+			fmt.Fprintf(w, `%s%s="%s"`, sep, l, escapeLabel(values[i]))
+			sep = ","
+		}
+		for i := 0; i < len(extra); i += 2 {
+			fmt.Fprintf(w, `%s%s="%s"`, sep, extra[i], escapeLabel(extra[i+1]))
+			sep = ","
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// labelEscaper escapes the three characters the format requires in label
+// values. Everything else is written as is.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// vec holds the series of a labelled metric, keyed by their label values.
+type vec[T any] struct {
+	desc
+	mu     sync.Mutex
+	series map[string]series[T]
+	create func() *T
+}
+
+type series[T any] struct {
+	values []string
+	m      *T
+}
+
+func newVec[T any](d desc, create func() *T) *vec[T] {
+	return &vec[T]{desc: d, series: make(map[string]series[T]), create: create}
+}
+
+// with returns the series for the given label values, creating it the first
+// time they are used.
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = series[T]{values: slices.Clone(values), m: v.create()}
+		v.series[key] = s
+	}
+	return s.m
+}
+
+// each calls fn for every series, sorted by label values so the output is
+// stable between scrapes.
+func (v *vec[T]) each(fn func(values []string, m *T)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	all := maps.Clone(v.series)
+	v.mu.Unlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		fn(all[k].values, all[k].m)
+	}
+}
+
+// atomicFloat is a float64 which can be updated atomically.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := f.bits.Load()
+		new := math.Float64bits(math.Float64frombits(old) + delta)
+		if f.bits.CompareAndSwap(old, new) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// TestExposition compares a registry's output with testdata/exposition.txt,
+// which was written by hand from the text format specification, so it must
+// not be regenerated from WriteTo itself.
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounter("requests_total", "Requests served.")
+	requests.Add(41)
+	requests.Inc()
+
+	r.NewGaugeFunc("temperature_celsius", "Help with a backslash (\\) and a\nnewline.", func() float64 { return -3.5 })
+
+	errors := r.NewCounterVec("errors_total", "Errors, by path and reason.", "path", "reason")
+	// Series are added out of order, to check that they come out sorted.
+	errors.With("/z", "plain").Inc()
+	errors.With("/a", `say "hi"`).Inc()
+	errors.With("/a", `C:\temp`).Add(2)
+	errors.With("/a", "line one\nline two").Add(3)
+
+	inFlight := r.NewGauge("in_flight", "Requests in progress.")
+	inFlight.Set(3)
+	inFlight.Inc()
+	inFlight.Dec()
+	inFlight.Dec()
+
+	// The buckets are given out of order too.
+	durations := r.NewHistogramVec("duration_seconds", "Request durations.", []float64{1, 0.1, 0.5}, "method")
+	get := durations.With("GET")
+	get.Observe(0.05)
+	get.Observe(0.1) // bounds are inclusive
+	get.Observe(0.3)
+	get.Observe(7)
+	durations.With("DELETE").Observe(0.25)
+
+	// A labelled metric with no series yet still gets its header.
+	r.NewCounterVec("unused_total", "Never incremented.", "label")
+
+	want, err := os.ReadFile("testdata/exposition.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(b.Len()))
+	if b.String() != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	// Scraping again gives exactly the same output.
+	var again strings.Builder
+	r.WriteTo(&again)
+	assert.Equal(t, again.String(), b.String())
+}
+
+func TestOnScrape(t *testing.T) {
+	r := NewRegistry()
+
+	var scrapes float64
+	r.OnScrape(func() { scrapes++ })
+	r.NewGaugeFunc("scrapes", "Scrapes so far.", func() float64 { return scrapes })
+
+	for range 2 {
+		rr := httptest.NewRecorder()
+		r.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Equal(t, rr.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+		assert.StringContains(t, rr.Body.String(), "\nscrapes "+formatFloat(scrapes)+"\n")
+	}
+	assert.Equal(t, scrapes, 2.0)
+}
+
+func TestPanics(t *testing.T) {
+	assertPanics := func(t *testing.T, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Error("didn't panic")
+			}
+		}()
+		fn()
+	}
+
+	t.Run("Duplicate name", func(t *testing.T) {
+		r := NewRegistry()
+		r.NewCounter("x", "")
+		assertPanics(t, func() { r.NewGauge("x", "") })
+	})
+
+	t.Run("Wrong number of labels", func(t *testing.T) {
+		cv := NewRegistry().NewCounterVec("x", "", "a", "b")
+		assertPanics(t, func() { cv.With("a") })
+	})
+
+	t.Run("Negative counter", func(t *testing.T) {
+		c := NewRegistry().NewCounter("x", "")
+		assertPanics(t, func() { c.Add(-1) })
+	})
+}
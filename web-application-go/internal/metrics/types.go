@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"bufio"
+	"slices"
+	"sort"
+	"sync/atomic"
+)
+
+// Counter is a value which only goes up, such as a number of requests.
+type Counter struct {
+	v atomicFloat
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() { c.v.add(1) }
+
+// Add adds delta, which must not be negative, to the counter.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.v.add(delta)
+}
+
+// NewCounter registers and returns a counter.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, &single{desc: desc{name: name, help: help, typ: "counter"}, value: c.v.load})
+	return c
+}
+
+// CounterVec is a counter with labels: one counter per combination of label
+// values.
+type CounterVec struct {
+	v *vec[Counter]
+}
+
+// NewCounterVec registers and returns a counter with the given labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	cv := &CounterVec{v: newVec(desc{name: name, help: help, typ: "counter", labels: labels}, func() *Counter { return &Counter{} })}
+	r.register(name, cv)
+	return cv
+}
+
+// With returns the counter for the given label values, in the order the
+// labels were declared.
+func (cv *CounterVec) With(values ...string) *Counter { return cv.v.with(values) }
+
+func (cv *CounterVec) write(w *bufio.Writer) {
+	cv.v.writeHeader(w)
+	cv.v.each(func(values []string, c *Counter) {
+		writeSample(w, cv.v.name, cv.v.labels, values, nil, c.v.load())
+	})
+}
+
+// Gauge is a value which can go up and down, such as a number of requests in
+// progress.
+type Gauge struct {
+	v atomicFloat
+}
+
+// Inc adds one to the gauge.
+func (g *Gauge) Inc() { g.v.add(1) }
+
+// Dec subtracts one from the gauge.
+func (g *Gauge) Dec() { g.v.add(-1) }
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) { g.v.set(v) }
+
+// NewGauge registers and returns a gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, &single{desc: desc{name: name, help: help, typ: "gauge"}, value: g.v.load})
+	return g
+}
+
+// NewGaugeFunc registers a gauge whose value is read by calling fn at each
+// scrape.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &single{desc: desc{name: name, help: help, typ: "gauge"}, value: fn})
+}
+
+// NewCounterFunc registers a counter whose value is read by calling fn at
+// each scrape, for counts kept elsewhere. fn must never return less than it
+// did before.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &single{desc: desc{name: name, help: help, typ: "counter"}, value: fn})
+}
+
+// single is an unlabelled metric with one value.
+type single struct {
+	desc
+	value func() float64
+}
+
+func (s *single) write(w *bufio.Writer) {
+	s.writeHeader(w)
+	writeSample(w, s.name, nil, nil, nil, s.value())
+}
+
+// DefBuckets are histogram buckets suited to HTTP request latencies, in
+// seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations, such as request durations, in buckets.
+type Histogram struct {
+	buckets []float64       // upper bounds, ascending
+	counts  []atomic.Uint64 // per bucket, plus one for +Inf; not cumulative
+	sum     atomicFloat
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets)+1)}
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	// Buckets are inclusive upper bounds, so v goes in the first bucket
+	// whose bound is at least v.
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.counts[i].Add(1)
+	h.sum.add(v)
+}
+
+func (h *Histogram) write(w *bufio.Writer, name string, labels, values []string) {
+	// Bucket counts are cumulative in the output, and the total count is
+	// worked out from them so the two always agree.
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i].Load()
+		writeSample(w, name+"_bucket", labels, values, []string{"le", formatFloat(bound)}, float64(cumulative))
+	}
+	cumulative += h.counts[len(h.buckets)].Load()
+	writeSample(w, name+"_bucket", labels, values, []string{"le", "+Inf"}, float64(cumulative))
+	writeSample(w, name+"_sum", labels, values, nil, h.sum.load())
+	writeSample(w, name+"_count", labels, values, nil, float64(cumulative))
+}
+
+// HistogramVec is a histogram with labels.
+type HistogramVec struct {
+	v *vec[Histogram]
+}
+
+// NewHistogramVec registers and returns a histogram with the given upper
+// bucket bounds and labels.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	hv := &HistogramVec{v: newVec(desc{name: name, help: help, typ: "histogram", labels: labels}, func() *Histogram {
+		return newHistogram(buckets)
+	})}
+	r.register(name, hv)
+	return hv
+}
+
+// With returns the histogram for the given label values, in the order the
+// labels were declared.
+func (hv *HistogramVec) With(values ...string) *Histogram { return hv.v.with(values) }
+
+func (hv *HistogramVec) write(w *bufio.Writer) {
+	hv.v.writeHeader(w)
+	hv.v.each(func(values []string, h *Histogram) {
+		h.write(w, hv.v.name, hv.v.labels, values)
+	})
+}
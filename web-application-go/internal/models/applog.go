@@ -0,0 +1,206 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// logEntry is a single line in an append-only log file. Op says what happened
+// (for example "insert" or "delete") and Data holds the JSON payload for it.
+type logEntry struct {
+	Op   string          `json:"op"`
+	Data json.RawMessage `json:"data"`
+}
+
+// appendLog is a newline-delimited JSON file which is only ever appended to.
+// The current state of a model is rebuilt by replaying every entry in order
+// when the file is opened.
+type appendLog struct {
+	file logFile
+
+	// size is the offset just after the last complete entry, which a
+	// failed append is truncated back to.
+	size int64
+
+	// err is set once the log couldn't be truncated after a failed append.
+	// Its end may then hold a partial line, so every later append fails.
+	err error
+}
+
+// logFile is the part of *os.File which the log uses. Tests replace it to make
+// writes fail part of the way through.
+type logFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Stat() (os.FileInfo, error)
+	Name() string
+	Close() error
+}
+
+// openAppendLog opens (or creates) the log at path and calls replay for every
+// entry already in it. If the process crashed half-way through writing the
+// final line, that partial line is truncated away rather than treated as an
+// error.
+func openAppendLog(path string, replay func(op string, data json.RawMessage) error) (*appendLog, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := replayAppendLog(file, replay)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("models: replaying %s: %w", path, err)
+	}
+
+	// Drop anything after the last complete line and position the file for
+	// further appends.
+	err = file.Truncate(offset)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &appendLog{file: file, size: offset}, nil
+}
+
+// replayAppendLog feeds every complete line to replay and returns the offset
+// just after the last one.
+func replayAppendLog(r io.Reader, replay func(op string, data json.RawMessage) error) (int64, error) {
+	var offset int64
+	reader := bufio.NewReader(r)
+
+	for line := 1; ; line++ {
+		b, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Either a clean end of file, or a partial line from an
+			// interrupted write. In both cases stop here.
+			return offset, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		offset += int64(len(b))
+
+		b = bytes.TrimSpace(b)
+		if len(b) == 0 {
+			continue
+		}
+
+		var entry logEntry
+		err = json.Unmarshal(b, &entry)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		err = replay(entry.Op, entry.Data)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// append writes a new entry to the end of the log and flushes it to disk
+// before returning, so a successful call survives a crash. A failed call
+// leaves the log as it was.
+func (l *appendLog) append(op string, data any) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(logEntry{Op: op, Data: payload})
+	if err != nil {
+		return err
+	}
+
+	line := append(b, '\n')
+	_, err = l.file.Write(line)
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		// Part of the line may have been written, or all of it without
+		// reaching the disk. The caller won't apply the entry either way,
+		// so cut it off: otherwise the next entry would be appended to a
+		// partial line, and replaying would fail on it at the next start.
+		return errors.Join(err, l.rewind())
+	}
+
+	l.size += int64(len(line))
+	return nil
+}
+
+// rewind truncates the log back to the end of its last complete entry.
+func (l *appendLog) rewind() error {
+	err := l.file.Truncate(l.size)
+	if err == nil {
+		_, err = l.file.Seek(l.size, io.SeekStart)
+	}
+	if err != nil {
+		l.err = fmt.Errorf("models: %s may end with a partial entry: %w", l.file.Name(), err)
+	}
+	return err
+}
+
+// checkWritable reports whether entries can still be appended: the file must
+// still be open, and its directory must accept a new file, which fails once
+// the disk is full or has been remounted read-only. The log itself is left
+// untouched; the check writes a small temporary file instead. A system call
+// can't be interrupted, so ctx is checked between them.
+func (l *appendLog) checkWritable(ctx context.Context) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	_, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(l.file.Name()), ".probe-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(probe.Name())
+
+	_, err = probe.Write([]byte("ok\n"))
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = probe.Sync()
+	}
+	return errors.Join(err, probe.Close())
+}
+
+func (l *appendLog) close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// recordReplay returns a replay function which records each entry it is
+// given as "op data".
+func recordReplay(got *[]string) func(op string, data json.RawMessage) error {
+	return func(op string, data json.RawMessage) error {
+		*got = append(*got, op+" "+string(data))
+		return nil
+	}
+}
+
+func TestReplayAppendLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		log        string
+		wantOps    []string
+		wantOffset int
+		wantErr    string
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name:       "Complete lines",
+			log:        `{"op":"insert","data":1}` + "\n" + `{"op":"delete","data":1}` + "\n",
+			wantOps:    []string{"insert 1", "delete 1"},
+			wantOffset: 50,
+		},
+		{
+			name:       "Blank lines",
+			log:        "\n" + `{"op":"insert","data":1}` + "\n\n",
+			wantOps:    []string{"insert 1"},
+			wantOffset: 27,
+		},
+		{
+			name:       "Torn last line",
+			log:        `{"op":"insert","data":1}` + "\n" + `{"op":"insert","da`,
+			wantOps:    []string{"insert 1"},
+			wantOffset: 25,
+		},
+		{
+			name:       "Complete last line without a newline",
+			log:        `{"op":"insert","data":1}` + "\n" + `{"op":"insert","data":2}`,
+			wantOps:    []string{"insert 1"},
+			wantOffset: 25,
+		},
+		{
+			name:    "Corrupt line in the middle",
+			log:     `{"op":"insert","data":1}` + "\n" + "not json\n" + `{"op":"insert","data":2}` + "\n",
+			wantErr: "line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			offset, err := replayAppendLog(strings.NewReader(tt.log), recordReplay(&got))
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("got no error; want one containing %q", tt.wantErr)
+				}
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, offset, int64(tt.wantOffset))
+			assert.Equal(t, strings.Join(got, ", "), strings.Join(tt.wantOps, ", "))
+		})
+	}
+}
+
+func TestOpenAppendLogTruncatesTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	complete := `{"op":"insert","data":1}` + "\n"
+	err := os.WriteFile(path, []byte(complete+`{"op":"ins`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	log, err := openAppendLog(path, recordReplay(&got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Join(got, ", "), "insert 1")
+
+	// The partial line is gone, and the next entry starts on a line of its
+	// own.
+	assert.NilError(t, log.append("insert", 2))
+	assert.NilError(t, log.close())
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(b), complete+`{"op":"insert","data":2}`+"\n")
+
+	got = nil
+	log, err = openAppendLog(path, recordReplay(&got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.close()
+	assert.Equal(t, strings.Join(got, ", "), "insert 1, insert 2")
+}
+
+func TestOpenAppendLogReplayError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	err := os.WriteFile(path, []byte(`{"op":"unknown","data":1}`+"\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenFileSnippetModel(path)
+	if err == nil {
+		t.Fatal("got no error for an unknown operation")
+	}
+	assert.StringContains(t, err.Error(), `unknown snippet operation "unknown"`)
+}
+
+// faultyFile is a log file whose writes, syncs or truncations can be made to
+// fail. A failing write gets half of its data to the file first, as a write
+// cut short by a full disk would.
+type faultyFile struct {
+	*os.File
+	failWrite, failSync, failTruncate bool
+}
+
+var errInjected = errors.New("injected failure")
+
+func (f *faultyFile) Write(b []byte) (int, error) {
+	if f.failWrite {
+		n, _ := f.File.Write(b[:len(b)/2])
+		return n, errInjected
+	}
+	return f.File.Write(b)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failSync {
+		return errInjected
+	}
+	return f.File.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errInjected
+	}
+	return f.File.Truncate(size)
+}
+
+// openFaultyLog opens a log at path whose file is wrapped in a faultyFile.
+func openFaultyLog(t *testing.T, path string) (*appendLog, *faultyFile) {
+	t.Helper()
+
+	log, err := openAppendLog(path, recordReplay(new([]string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &faultyFile{File: log.file.(*os.File)}
+	log.file = f
+	t.Cleanup(func() { log.close() })
+	return log, f
+}
+
+func TestAppendLogFailedWrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault func(f *faultyFile)
+	}{
+		{"Partial write", func(f *faultyFile) { f.failWrite = true }},
+		{"Failed sync", func(f *faultyFile) { f.failSync = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.log")
+			log, f := openFaultyLog(t, path)
+
+			assert.NilError(t, log.append("insert", 1))
+
+			tt.fault(f)
+			err := log.append("insert", 2)
+			assert.ErrorIs(t, err, errInjected)
+
+			// Once the fault clears, the log carries on from its last
+			// complete entry.
+			*f = faultyFile{File: f.File}
+			assert.NilError(t, log.append("insert", 3))
+			assert.NilError(t, log.checkWritable(t.Context()))
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(b), `{"op":"insert","data":1}`+"\n"+`{"op":"insert","data":3}`+"\n")
+
+			var got []string
+			reopened, err := openAppendLog(path, recordReplay(&got))
+			assert.NilError(t, err)
+			defer reopened.close()
+			assert.Equal(t, strings.Join(got, ", "), "insert 1, insert 3")
+		})
+	}
+}
+
+// TestAppendLogFailedRewind checks that a log which couldn't be cleaned up
+// after a failed write refuses further entries, rather than appending them
+// to a partial line.
+func TestAppendLogFailedRewind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	log, f := openFaultyLog(t, path)
+
+	assert.NilError(t, log.append("insert", 1))
+
+	f.failWrite, f.failTruncate = true, true
+	err := log.append("insert", 2)
+	assert.ErrorIs(t, err, errInjected)
+
+	*f = faultyFile{File: f.File}
+	err = log.append("insert", 3)
+	if err == nil {
+		t.Fatal("append succeeded after the log was damaged")
+	}
+	assert.StringContains(t, err.Error(), "may end with a partial entry")
+
+	err = log.checkWritable(t.Context())
+	if err == nil {
+		t.Fatal("checkWritable passed after the log was damaged")
+	}
+
+	// Nothing was written after the partial line.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), `"data":3`) {
+		t.Errorf("got %q; want no third entry", b)
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "errors"
+
+// ErrNoRecord is returned when a lookup does not match any stored record.
+// Handlers check for it with errors.Is() so they can send a 404 instead of a
+// 500, whatever storage backend is in use.
+var ErrNoRecord = errors.New("models: no matching record found")
+
+// ErrInvalidCredentials is returned by UserModel.Authenticate when the email
+// address is unknown or the password doesn't match.
+var ErrInvalidCredentials = errors.New("models: invalid credentials")
+
+// ErrDuplicateEmail is returned by UserModel.Insert when the email address is
+// already registered.
+var ErrDuplicateEmail = errors.New("models: duplicate email")
+
+// ErrNoChange is returned by SnippetModel.Update when the update leaves the
+// snippet as it is, so there is nothing to make a revision of.
+var ErrNoChange = errors.New("models: no change")
@@ -0,0 +1,356 @@
+package models
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snippet holds the data for an individual snippet. The struct tags are used
+// by the file-backed model when it writes records to its log.
+type Snippet struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	// AuthorID is the ID of the user who created the snippet. Snippets
+	// created before user accounts existed have an AuthorID of 0.
+	AuthorID int `json:"author_id,omitempty"`
+	// Tags are short lowercase labels used to filter the listings.
+	Tags []string `json:"tags,omitempty"`
+	// Language is the name of the language the content is highlighted as.
+	// It is empty for snippets created before languages were recorded.
+	Language string `json:"language,omitempty"`
+	// Revision is the number of the current revision, starting at 1, and
+	// Updated is when it was made. Updated is zero until the first edit.
+	Revision int       `json:"revision,omitempty"`
+	Updated  time.Time `json:"updated,omitempty"`
+}
+
+// Revision is one version of a snippet's editable fields. Revisions are
+// never changed once stored: an edit adds a new one, and so does restoring
+// an old one. Revision 1 is the snippet as it was created.
+type Revision struct {
+	Number   int       `json:"number"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Tags     []string  `json:"tags,omitempty"`
+	Language string    `json:"language,omitempty"`
+	Created  time.Time `json:"created"`
+	// AuthorID is the user who made this revision, who may not be the
+	// author of the snippet.
+	AuthorID int `json:"author_id,omitempty"`
+	// RestoredFrom is the number of the revision this one restored, or 0
+	// for an ordinary edit.
+	RestoredFrom int `json:"restored_from,omitempty"`
+}
+
+// SnippetUpdate holds the new values of a snippet's editable fields. The
+// expiry can't be changed.
+type SnippetUpdate struct {
+	Title    string
+	Content  string
+	Tags     []string
+	Language string
+	// AuthorID is the user making the change.
+	AuthorID     int
+	RestoredFrom int
+}
+
+// NewSnippet holds the fields supplied when a snippet is created. The ID and
+// timestamps are filled in by the model.
+type NewSnippet struct {
+	Title   string
+	Content string
+	// Expires is the number of days until the snippet expires.
+	Expires  int
+	AuthorID int
+	Tags     []string
+	Language string
+}
+
+// ListQuery selects a page of snippets for SnippetModel.List. The zero
+// value of each filter field means "don't filter on this".
+type ListQuery struct {
+	AuthorID int
+	Tag      string
+	// Before is a cursor: only snippets with a lower ID are returned. Since
+	// IDs only ever increase, new snippets can't shift the pages which come
+	// after a cursor. Zero starts from the newest snippet.
+	Before int
+	// Limit is the maximum number of snippets to return. Zero means no
+	// limit.
+	Limit int
+}
+
+// SnippetModel is the storage abstraction used by the handlers. Anything that
+// satisfies it can be plugged into the application struct: the in-memory model
+// for tests, or the file-backed model for a server that survives restarts.
+type SnippetModel interface {
+	// Insert stores a new snippet and returns its ID.
+	Insert(snippet NewSnippet) (int, error)
+	// Get returns the snippet with the given ID, or ErrNoRecord. Expired
+	// snippets are treated as if they didn't exist.
+	Get(id int) (Snippet, error)
+	// List returns the unexpired snippets matching the query, newest first.
+	// If there are more after them, next is the cursor for the following
+	// page (to use as ListQuery.Before); otherwise it is 0.
+	List(q ListQuery) (snippets []Snippet, next int, err error)
+	// Update stores a new revision of the snippet with the given ID and
+	// makes it current. It returns ErrNoRecord if there is no such snippet,
+	// and ErrNoChange if the update is the same as the current revision.
+	Update(id int, u SnippetUpdate) (Revision, error)
+	// Revisions returns every revision of the snippet with the given ID,
+	// oldest first, or ErrNoRecord.
+	Revisions(id int) ([]Revision, error)
+	// Delete removes the snippet with the given ID, along with its history,
+	// or returns ErrNoRecord.
+	Delete(id int) error
+	// DeleteExpired permanently removes every expired snippet and returns
+	// their IDs.
+	DeleteExpired() ([]int, error)
+}
+
+// Expired reports whether the snippet's expiry time has passed.
+func (s Snippet) Expired() bool {
+	return !time.Now().Before(s.Expires)
+}
+
+// snippetSet is the in-memory state shared by both SnippetModel
+// implementations. It is not safe for concurrent use on its own; callers are
+// expected to hold their own lock.
+type snippetSet struct {
+	lastID    int
+	snippets  map[int]Snippet
+	revisions map[int][]Revision
+}
+
+func newSnippetSet() *snippetSet {
+	return &snippetSet{snippets: make(map[int]Snippet), revisions: make(map[int][]Revision)}
+}
+
+// build prepares a new snippet with the next available ID, without storing it.
+func (s *snippetSet) build(n NewSnippet) Snippet {
+	now := time.Now().UTC()
+	return Snippet{
+		ID:       s.lastID + 1,
+		Title:    n.Title,
+		Content:  n.Content,
+		Created:  now,
+		Expires:  now.AddDate(0, 0, n.Expires),
+		AuthorID: n.AuthorID,
+		Tags:     n.Tags,
+		Language: n.Language,
+		Revision: 1,
+	}
+}
+
+// put stores a new snippet, with the snippet itself as its first revision.
+func (s *snippetSet) put(snippet Snippet) {
+	// Snippets stored before revisions existed have no revision number.
+	snippet.Revision = 1
+
+	s.snippets[snippet.ID] = snippet
+	s.revisions[snippet.ID] = []Revision{{
+		Number:   1,
+		Title:    snippet.Title,
+		Content:  snippet.Content,
+		Tags:     snippet.Tags,
+		Language: snippet.Language,
+		Created:  snippet.Created,
+		AuthorID: snippet.AuthorID,
+	}}
+	if snippet.ID > s.lastID {
+		s.lastID = snippet.ID
+	}
+}
+
+// buildRevision prepares the next revision of a snippet from an update,
+// without storing it.
+func (s *snippetSet) buildRevision(id int, u SnippetUpdate) (Revision, error) {
+	snippet, err := s.get(id)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	if u.Title == snippet.Title && u.Content == snippet.Content &&
+		slices.Equal(u.Tags, snippet.Tags) && u.Language == snippet.Language {
+		return Revision{}, ErrNoChange
+	}
+
+	return Revision{
+		Number:       snippet.Revision + 1,
+		Title:        u.Title,
+		Content:      u.Content,
+		Tags:         u.Tags,
+		Language:     u.Language,
+		Created:      time.Now().UTC(),
+		AuthorID:     u.AuthorID,
+		RestoredFrom: u.RestoredFrom,
+	}, nil
+}
+
+// addRevision stores a revision and makes it the snippet's current version.
+func (s *snippetSet) addRevision(id int, rev Revision) error {
+	snippet, ok := s.snippets[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	snippet.Title = rev.Title
+	snippet.Content = rev.Content
+	snippet.Tags = rev.Tags
+	snippet.Language = rev.Language
+	snippet.Revision = rev.Number
+	snippet.Updated = rev.Created
+
+	s.snippets[id] = snippet
+	s.revisions[id] = append(s.revisions[id], rev)
+	return nil
+}
+
+// history returns a copy of the revisions of an unexpired snippet.
+func (s *snippetSet) history(id int) ([]Revision, error) {
+	if _, err := s.get(id); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.revisions[id]), nil
+}
+
+func (s *snippetSet) get(id int) (Snippet, error) {
+	snippet, ok := s.snippets[id]
+	if !ok || snippet.Expired() {
+		return Snippet{}, ErrNoRecord
+	}
+	return snippet, nil
+}
+
+// list returns the unexpired snippets matching q, newest first, and the
+// cursor for the next page.
+func (s *snippetSet) list(q ListQuery) ([]Snippet, int) {
+	ids := make([]int, 0, len(s.snippets))
+	for id := range s.snippets {
+		if q.Before == 0 || id < q.Before {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+
+	snippets := []Snippet{}
+	for _, id := range ids {
+		snippet := s.snippets[id]
+		if snippet.Expired() {
+			continue
+		}
+		if q.AuthorID != 0 && snippet.AuthorID != q.AuthorID {
+			continue
+		}
+		if q.Tag != "" && !slices.Contains(snippet.Tags, q.Tag) {
+			continue
+		}
+
+		// Collect one more than asked for, to find out whether there is
+		// another page.
+		if q.Limit > 0 && len(snippets) == q.Limit {
+			return snippets, snippets[len(snippets)-1].ID
+		}
+		snippets = append(snippets, snippet)
+	}
+
+	return snippets, 0
+}
+
+// expired returns the IDs of every expired snippet, in ascending order.
+func (s *snippetSet) expired() []int {
+	var ids []int
+	for id, snippet := range s.snippets {
+		if snippet.Expired() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *snippetSet) remove(id int) error {
+	if _, ok := s.snippets[id]; !ok {
+		return ErrNoRecord
+	}
+	delete(s.snippets, id)
+	delete(s.revisions, id)
+	return nil
+}
+
+// MemorySnippetModel is a SnippetModel with no storage behind it. It starts
+// empty each time, which is what the model tests and handler tests want.
+type MemorySnippetModel struct {
+	mu  sync.RWMutex
+	set *snippetSet
+}
+
+// NewMemorySnippetModel returns an empty in-memory snippet model.
+func NewMemorySnippetModel() *MemorySnippetModel {
+	return &MemorySnippetModel{set: newSnippetSet()}
+}
+
+func (m *MemorySnippetModel) Insert(n NewSnippet) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippet := m.set.build(n)
+	m.set.put(snippet)
+	return snippet.ID, nil
+}
+
+func (m *MemorySnippetModel) Get(id int) (Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.set.get(id)
+}
+
+func (m *MemorySnippetModel) List(q ListQuery) ([]Snippet, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snippets, next := m.set.list(q)
+	return snippets, next, nil
+}
+
+func (m *MemorySnippetModel) Update(id int, u SnippetUpdate) (Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rev, err := m.set.buildRevision(id, u)
+	if err != nil {
+		return Revision{}, err
+	}
+	return rev, m.set.addRevision(id, rev)
+}
+
+func (m *MemorySnippetModel) Revisions(id int) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.set.history(id)
+}
+
+func (m *MemorySnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.set.remove(id)
+}
+
+func (m *MemorySnippetModel) DeleteExpired() ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.set.expired()
+	for _, id := range ids {
+		m.set.remove(id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// FileSnippetModel stores snippets in an append-only log on disk, so they
+// survive a restart. The whole set is also kept in memory, which means reads
+// never touch the file.
+type FileSnippetModel struct {
+	mu  sync.RWMutex
+	set *snippetSet
+	log *appendLog
+}
+
+// OpenFileSnippetModel opens the snippet log at path, creating it if needed,
+// and loads every snippet it contains.
+func OpenFileSnippetModel(path string) (*FileSnippetModel, error) {
+	m := &FileSnippetModel{set: newSnippetSet()}
+
+	log, err := openAppendLog(path, m.replay)
+	if err != nil {
+		return nil, err
+	}
+	m.log = log
+
+	return m, nil
+}
+
+func (m *FileSnippetModel) replay(op string, data json.RawMessage) error {
+	switch op {
+	case "insert":
+		var snippet Snippet
+		err := json.Unmarshal(data, &snippet)
+		if err != nil {
+			return err
+		}
+		m.set.put(snippet)
+	case "update":
+		var entry revisionEntry
+		err := json.Unmarshal(data, &entry)
+		if err != nil {
+			return err
+		}
+		// Like a delete, an update for a snippet we don't know about is
+		// harmless.
+		m.set.addRevision(entry.ID, entry.Revision)
+	case "delete":
+		var id int
+		err := json.Unmarshal(data, &id)
+		if err != nil {
+			return err
+		}
+		// A delete for a snippet we don't know about is harmless.
+		m.set.remove(id)
+	case "delete_expired":
+		var ids []int
+		err := json.Unmarshal(data, &ids)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			m.set.remove(id)
+		}
+	default:
+		return fmt.Errorf("unknown snippet operation %q", op)
+	}
+	return nil
+}
+
+// Insert writes the new snippet to the log before making it visible, so a
+// failed write never leaves a snippet that would disappear on restart.
+func (m *FileSnippetModel) Insert(n NewSnippet) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippet := m.set.build(n)
+
+	err := m.log.append("insert", snippet)
+	if err != nil {
+		return 0, err
+	}
+
+	m.set.put(snippet)
+	return snippet.ID, nil
+}
+
+func (m *FileSnippetModel) Get(id int) (Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.set.get(id)
+}
+
+func (m *FileSnippetModel) List(q ListQuery) ([]Snippet, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snippets, next := m.set.list(q)
+	return snippets, next, nil
+}
+
+// revisionEntry is the log entry for an update: the new revision, in full,
+// and the snippet it belongs to.
+type revisionEntry struct {
+	ID       int      `json:"id"`
+	Revision Revision `json:"revision"`
+}
+
+// Update writes the new revision to the log before making it current, like
+// Insert.
+func (m *FileSnippetModel) Update(id int, u SnippetUpdate) (Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rev, err := m.set.buildRevision(id, u)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	err = m.log.append("update", revisionEntry{ID: id, Revision: rev})
+	if err != nil {
+		return Revision{}, err
+	}
+
+	return rev, m.set.addRevision(id, rev)
+}
+
+func (m *FileSnippetModel) Revisions(id int) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.set.history(id)
+}
+
+func (m *FileSnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.set.snippets[id]; !ok {
+		return ErrNoRecord
+	}
+
+	err := m.log.append("delete", id)
+	if err != nil {
+		return err
+	}
+
+	return m.set.remove(id)
+}
+
+// DeleteExpired records all the removals in a single log entry, so a reaper
+// pass costs one write however many snippets it removes.
+func (m *FileSnippetModel) DeleteExpired() ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.set.expired()
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	err := m.log.append("delete_expired", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		m.set.remove(id)
+	}
+	return ids, nil
+}
+
+// CheckWritable reports whether the log can still be written to. It gives up
+// early if ctx is done.
+func (m *FileSnippetModel) CheckWritable(ctx context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.log.checkWritable(ctx)
+}
+
+// Close releases the underlying log file.
+func (m *FileSnippetModel) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.log.close()
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// openSnippets opens the file-backed model at path, failing the test if it
+// can't, and closes it when the test ends.
+func openSnippets(t *testing.T, path string) *FileSnippetModel {
+	t.Helper()
+
+	m, err := OpenFileSnippetModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func insertSnippet(t *testing.T, m SnippetModel, title string, expires int) int {
+	t.Helper()
+
+	id, err := m.Insert(NewSnippet{Title: title, Content: title + " content", Expires: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// TestSnippetModels runs the same checks against both implementations.
+func TestSnippetModels(t *testing.T) {
+	models := map[string]func(t *testing.T) SnippetModel{
+		"Memory": func(t *testing.T) SnippetModel {
+			return NewMemorySnippetModel()
+		},
+		"File": func(t *testing.T) SnippetModel {
+			return openSnippets(t, filepath.Join(t.TempDir(), "snippets.log"))
+		},
+	}
+
+	for name, open := range models {
+		t.Run(name, func(t *testing.T) {
+			m := open(t)
+
+			id := insertSnippet(t, m, "First", 7)
+			assert.Equal(t, id, 1)
+
+			s, err := m.Get(id)
+			assert.NilError(t, err)
+			assert.Equal(t, s.Title, "First")
+			assert.Equal(t, s.Revision, 1)
+
+			_, err = m.Get(99)
+			assert.ErrorIs(t, err, ErrNoRecord)
+
+			rev, err := m.Update(id, SnippetUpdate{Title: "First, edited", Content: s.Content})
+			assert.NilError(t, err)
+			assert.Equal(t, rev.Number, 2)
+
+			_, err = m.Update(id, SnippetUpdate{Title: "First, edited", Content: s.Content})
+			assert.ErrorIs(t, err, ErrNoChange)
+
+			revs, err := m.Revisions(id)
+			assert.NilError(t, err)
+			assert.Equal(t, len(revs), 2)
+
+			expired := insertSnippet(t, m, "Expired", -1)
+			_, err = m.Get(expired)
+			assert.ErrorIs(t, err, ErrNoRecord)
+
+			ids, err := m.DeleteExpired()
+			assert.NilError(t, err)
+			assert.Equal(t, len(ids), 1)
+
+			assert.NilError(t, m.Delete(id))
+			assert.ErrorIs(t, m.Delete(id), ErrNoRecord)
+			_, err = m.Revisions(id)
+			assert.ErrorIs(t, err, ErrNoRecord)
+		})
+	}
+}
+
+// TestFileSnippetModelReplay makes one of each kind of change, then checks
+// that reopening the log brings back the same state.
+func TestFileSnippetModelReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippets.log")
+
+	m := openSnippets(t, path)
+	kept := insertSnippet(t, m, "Kept", 7)
+	deleted := insertSnippet(t, m, "Deleted", 7)
+	expired := insertSnippet(t, m, "Expired", -1)
+
+	_, err := m.Update(kept, SnippetUpdate{Title: "Kept, edited", Content: "new content", Tags: []string{"go"}})
+	assert.NilError(t, err)
+	assert.NilError(t, m.Delete(deleted))
+	ids, err := m.DeleteExpired()
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 1)
+	assert.NilError(t, m.Close())
+
+	m = openSnippets(t, path)
+
+	s, err := m.Get(kept)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Kept, edited")
+	assert.Equal(t, s.Content, "new content")
+	assert.Equal(t, strings.Join(s.Tags, ","), "go")
+	assert.Equal(t, s.Revision, 2)
+
+	revs, err := m.Revisions(kept)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revs), 2)
+	assert.Equal(t, revs[0].Title, "Kept")
+
+	_, err = m.Get(deleted)
+	assert.ErrorIs(t, err, ErrNoRecord)
+
+	// The expired snippet's record is gone, not just hidden.
+	m.mu.RLock()
+	_, ok := m.set.snippets[expired]
+	m.mu.RUnlock()
+	assert.Equal(t, ok, false)
+}
+
+// TestFileSnippetModelIDsAfterRestart checks that an ID is never handed out
+// twice, even when the snippet which had it was the newest and is gone by the
+// time the log is replayed.
+func TestFileSnippetModelIDsAfterRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(m *FileSnippetModel, id int) error
+	}{
+		{
+			name: "Delete",
+			remove: func(m *FileSnippetModel, id int) error {
+				return m.Delete(id)
+			},
+		},
+		{
+			name: "Delete expired",
+			remove: func(m *FileSnippetModel, id int) error {
+				_, err := m.DeleteExpired()
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snippets.log")
+
+			m := openSnippets(t, path)
+			insertSnippet(t, m, "Older", 7)
+			newest := insertSnippet(t, m, "Newest", -1)
+			assert.NilError(t, tt.remove(m, newest))
+			assert.NilError(t, m.Close())
+
+			m = openSnippets(t, path)
+			id := insertSnippet(t, m, "After restart", 7)
+			assert.Equal(t, id, newest+1)
+		})
+	}
+}
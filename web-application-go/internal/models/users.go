@@ -0,0 +1,197 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User holds the data for a registered user. HashedPassword is a bcrypt hash;
+// the plain-text password is never stored.
+type User struct {
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	HashedPassword []byte    `json:"hashed_password"`
+	Created        time.Time `json:"created"`
+}
+
+// UserModel is the storage abstraction for user accounts, with the same two
+// kinds of implementation as SnippetModel.
+type UserModel interface {
+	// Insert creates a new user and returns its ID. It returns
+	// ErrDuplicateEmail if the email address is already in use.
+	Insert(name, email, password string) (int, error)
+	// Authenticate returns the ID of the user with the given email address
+	// and password, or ErrInvalidCredentials.
+	Authenticate(email, password string) (int, error)
+	// Get returns the user with the given ID, or ErrNoRecord.
+	Get(id int) (User, error)
+	// Exists reports whether a user with the given ID exists.
+	Exists(id int) (bool, error)
+}
+
+// bcryptCost is the work factor used when hashing passwords.
+const bcryptCost = 12
+
+// normalizeEmail is applied to every email address before it is stored or
+// looked up, so that uniqueness doesn't depend on letter case.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// userSet is the in-memory state shared by both UserModel implementations. It
+// is not safe for concurrent use on its own.
+type userSet struct {
+	lastID  int
+	users   map[int]User
+	byEmail map[string]int
+}
+
+func newUserSet() *userSet {
+	return &userSet{
+		users:   make(map[int]User),
+		byEmail: make(map[string]int),
+	}
+}
+
+// hashPassword returns the bcrypt hash of password. It is slow on purpose,
+// so callers run it before taking their lock.
+func hashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+}
+
+// build prepares a new user with the next available ID and an already hashed
+// password, without storing it.
+func (s *userSet) build(name, email string, hashedPassword []byte) (User, error) {
+	email = normalizeEmail(email)
+	if _, exists := s.byEmail[email]; exists {
+		return User{}, ErrDuplicateEmail
+	}
+
+	return User{
+		ID:             s.lastID + 1,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+	}, nil
+}
+
+func (s *userSet) put(user User) {
+	s.users[user.ID] = user
+	s.byEmail[user.Email] = user.ID
+	if user.ID > s.lastID {
+		s.lastID = user.ID
+	}
+}
+
+func (s *userSet) get(id int) (User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, ErrNoRecord
+	}
+	return user, nil
+}
+
+// credentials returns the ID and password hash of the user with the given
+// email address. ok is false if there is no such user.
+func (s *userSet) credentials(email string) (id int, hashedPassword []byte, ok bool) {
+	id, ok = s.byEmail[normalizeEmail(email)]
+	if !ok {
+		return 0, nil, false
+	}
+	return id, s.users[id].HashedPassword, true
+}
+
+// dummyHash is compared against when a login names an unknown email address,
+// so that it takes as long as one with a wrong password.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := hashPassword("snippetbox dummy password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// checkPassword finishes a login once credentials has looked up the user,
+// without holding any lock. An unknown email address and a wrong password
+// take the same time and give the same error, so callers can't tell which
+// accounts exist.
+func checkPassword(id int, hashedPassword []byte, found bool, password string) (int, error) {
+	if !found {
+		hashedPassword = dummyHash()
+	}
+
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+	if !found {
+		return 0, ErrInvalidCredentials
+	}
+
+	return id, nil
+}
+
+// MemoryUserModel keeps users in memory only, so every account is gone when
+// the process exits.
+type MemoryUserModel struct {
+	mu  sync.RWMutex
+	set *userSet
+}
+
+// NewMemoryUserModel returns an empty in-memory user model.
+func NewMemoryUserModel() *MemoryUserModel {
+	return &MemoryUserModel{set: newUserSet()}
+}
+
+func (m *MemoryUserModel) Insert(name, email, password string) (int, error) {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, err := m.set.build(name, email, hashedPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	m.set.put(user)
+	return user.ID, nil
+}
+
+// Authenticate only holds the lock to look the user up: bcrypt is
+// deliberately slow, and a waiting writer would block every other reader
+// until the comparison finished.
+func (m *MemoryUserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	id, hashedPassword, found := m.set.credentials(email)
+	m.mu.RUnlock()
+
+	return checkPassword(id, hashedPassword, found, password)
+}
+
+func (m *MemoryUserModel) Get(id int) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.set.get(id)
+}
+
+func (m *MemoryUserModel) Exists(id int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.set.users[id]
+	return ok, nil
+}
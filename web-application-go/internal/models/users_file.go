@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// FileUserModel stores users in an append-only log on disk, in the same way
+// as FileSnippetModel.
+type FileUserModel struct {
+	mu  sync.RWMutex
+	set *userSet
+	log *appendLog
+}
+
+// OpenFileUserModel opens the user log at path, creating it if needed, and
+// loads every user it contains.
+func OpenFileUserModel(path string) (*FileUserModel, error) {
+	m := &FileUserModel{set: newUserSet()}
+
+	log, err := openAppendLog(path, m.replay)
+	if err != nil {
+		return nil, err
+	}
+	m.log = log
+
+	return m, nil
+}
+
+func (m *FileUserModel) replay(op string, data json.RawMessage) error {
+	switch op {
+	case "insert":
+		var user User
+		err := json.Unmarshal(data, &user)
+		if err != nil {
+			return err
+		}
+		m.set.put(user)
+	default:
+		return fmt.Errorf("unknown user operation %q", op)
+	}
+	return nil
+}
+
+// Insert hashes the password before taking the lock, so a signup doesn't
+// hold up every other request for the time bcrypt takes.
+func (m *FileUserModel) Insert(name, email, password string) (int, error) {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, err := m.set.build(name, email, hashedPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	err = m.log.append("insert", user)
+	if err != nil {
+		return 0, err
+	}
+
+	m.set.put(user)
+	return user.ID, nil
+}
+
+func (m *FileUserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	id, hashedPassword, found := m.set.credentials(email)
+	m.mu.RUnlock()
+
+	return checkPassword(id, hashedPassword, found, password)
+}
+
+func (m *FileUserModel) Get(id int) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.set.get(id)
+}
+
+func (m *FileUserModel) Exists(id int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.set.users[id]
+	return ok, nil
+}
+
+// CheckWritable reports whether the log can still be written to. It gives up
+// early if ctx is done.
+func (m *FileUserModel) CheckWritable(ctx context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.log.checkWritable(ctx)
+}
+
+// Close releases the underlying log file.
+func (m *FileUserModel) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.log.close()
+}
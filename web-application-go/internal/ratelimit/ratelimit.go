@@ -0,0 +1,276 @@
+// Package ratelimit limits how often each client may do something, using a
+// token bucket per client.
+//
+// Requests over the limit are refused rather than delayed. Buckets don't
+// need a goroutine to top them up: each one works out the tokens it has
+// gained from the time since it was last used. A bucket which has filled up
+// again is no different from a new client's, so it can be dropped, which is
+// what keeps memory bounded, along with a cap on the clients tracked in
+// total and in each group of related keys.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a number of requests allowed per period, such as 100 requests per
+// 10 seconds. A client may use all of them at once, after which they come
+// back steadily over the period. The zero Limit allows everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0
+}
+
+// String formats the limit as in "100/10s", or "off" if it is disabled.
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	// Drop the zero units time.Duration adds, so 1m0s reads as 1m.
+	per := l.Per.String()
+	if strings.HasSuffix(per, "m0s") {
+		per = strings.TrimSuffix(per, "0s")
+	}
+	if strings.HasSuffix(per, "h0m") {
+		per = strings.TrimSuffix(per, "0m")
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, per)
+}
+
+// MarshalText implements encoding.TextMarshaler, so a Limit can be used with
+// flag.TextVar.
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses a limit written as "<requests>/<duration>", such as
+// "100/10s" or "10/15m", or "off" to disable it.
+func (l *Limit) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if s == "off" || s == "0" {
+		*l = Limit{}
+		return nil
+	}
+
+	n, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return errors.New(`must look like "100/10s" or be "off"`)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 1 {
+		return errors.New("number of requests must be a positive integer")
+	}
+	period, err := time.ParseDuration(per)
+	if err != nil || period <= 0 {
+		return errors.New("period must be a positive duration, such as 10s or 15m")
+	}
+
+	*l = Limit{Requests: requests, Per: period}
+	return nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	group  string
+}
+
+// Limiter applies a Limit to each client separately. Clients are told apart
+// by a key, usually their IP address, and belong to a group, such as the
+// network the address is in. It is safe for concurrent use.
+type Limiter struct {
+	limit       Limit
+	rate        float64 // tokens gained per second
+	maxClients  int
+	maxPerGroup int
+
+	mu      sync.Mutex
+	clients map[string]*bucket
+	groups  map[string]map[string]*bucket
+}
+
+// New returns a limiter which applies limit to each client, and tracks at
+// most maxClients clients at once, of which at most maxPerGroup may be in
+// the same group.
+//
+// Without the group limit, whoever controls enough addresses could fill the
+// table with clients which are all being limited. Every new client would
+// then be refused until those buckets refilled. With it, filling the table
+// takes maxClients/maxPerGroup groups, and a full group only turns away new
+// clients from that group.
+func New(limit Limit, maxClients, maxPerGroup int) *Limiter {
+	l := &Limiter{
+		limit:       limit,
+		maxClients:  maxClients,
+		maxPerGroup: maxPerGroup,
+		clients:     make(map[string]*bucket),
+		groups:      make(map[string]map[string]*bucket),
+	}
+	if limit.Enabled() {
+		l.rate = float64(limit.Requests) / limit.Per.Seconds()
+	}
+	return l
+}
+
+// Limit returns the limit the limiter applies.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow takes a token from the bucket of the client with the given key,
+// which is in group. It reports whether there was one and, if not, how long
+// until there will be. When the limiter, or the client's group, already has
+// as many clients as it can track and none of them is idle, a new client is
+// refused too, rather than having its bucket made by throwing away a client
+// that is still being limited.
+func (l *Limiter) Allow(group, key string) (ok bool, retryAfter time.Duration) {
+	if !l.limit.Enabled() {
+		return true, 0
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, found := l.clients[key]
+	if found {
+		l.refill(b, now)
+	} else {
+		if len(l.groups[group]) >= l.maxPerGroup {
+			if ok, retryAfter := l.evict(l.groups[group], now); !ok {
+				return false, retryAfter
+			}
+		}
+		if len(l.clients) >= l.maxClients {
+			if ok, retryAfter := l.evict(l.clients, now); !ok {
+				return false, retryAfter
+			}
+		}
+		b = &bucket{tokens: float64(l.limit.Requests), last: now, group: group}
+		l.add(key, b)
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, l.duration(1 - b.tokens)
+}
+
+// duration returns how long it takes to gain the given number of tokens. It
+// is rounded to the nearest nanosecond rather than up, since rounding up
+// would turn floating-point error into a whole extra second of Retry-After.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Round(tokens / l.rate * float64(time.Second)))
+}
+
+// refill adds the tokens the bucket has gained since it was last used.
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = min(float64(l.limit.Requests), b.tokens+elapsed*l.rate)
+	b.last = now
+}
+
+// missing returns how many tokens the bucket is short of full by now.
+func (l *Limiter) missing(b *bucket, now time.Time) float64 {
+	return float64(l.limit.Requests) - b.tokens - now.Sub(b.last).Seconds()*l.rate
+}
+
+// full reports whether the bucket would be full by now. A full bucket is the
+// same as no bucket, so it can be forgotten.
+func (l *Limiter) full(b *bucket, now time.Time) bool {
+	return l.missing(b, now) <= 0
+}
+
+// add starts tracking a client. The caller must hold l.mu.
+func (l *Limiter) add(key string, b *bucket) {
+	l.clients[key] = b
+	if l.groups[b.group] == nil {
+		l.groups[b.group] = make(map[string]*bucket)
+	}
+	l.groups[b.group][key] = b
+}
+
+// remove stops tracking a client. The caller must hold l.mu.
+func (l *Limiter) remove(key string, b *bucket) {
+	delete(l.clients, key)
+	delete(l.groups[b.group], key)
+	if len(l.groups[b.group]) == 0 {
+		delete(l.groups, b.group)
+	}
+}
+
+// evictSample is how many clients evict looks at to find an idle one.
+const evictSample = 16
+
+// evict makes room for a new client by dropping an idle one from clients
+// (either every client, or those of one group): a client whose bucket has
+// filled up again, so that forgetting it changes nothing. Only a few
+// clients, picked at random (Go's map order is random), are looked at, so
+// that a full limiter costs the same as an empty one; Cleanup scans every
+// client in the background instead.
+//
+// Clients that are still being limited are never dropped, since that would
+// reset their bucket: someone with many addresses could otherwise churn the
+// table to clear limits, their own included. If none of the sample is idle,
+// evict returns false and how long until the first of them will be. The
+// caller must hold l.mu.
+func (l *Limiter) evict(clients map[string]*bucket, now time.Time) (ok bool, retryAfter time.Duration) {
+	retryAfter, seen := time.Duration(math.MaxInt64), 0
+	for key, b := range clients {
+		missing := l.missing(b, now)
+		if missing <= 0 {
+			l.remove(key, b)
+			return true, 0
+		}
+		retryAfter = min(retryAfter, l.duration(missing))
+
+		seen++
+		if seen == evictSample {
+			break
+		}
+	}
+	return false, retryAfter
+}
+
+func (l *Limiter) removeIdle(now time.Time) int {
+	removed := 0
+	for key, b := range l.clients {
+		if l.full(b, now) {
+			l.remove(key, b)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Cleanup forgets the clients which have been idle long enough for their
+// bucket to fill up again, and returns how many there were. It should be
+// called periodically.
+func (l *Limiter) Cleanup() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.removeIdle(time.Now())
+}
+
+// Len returns the number of clients being tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.clients)
+}
@@ -0,0 +1,215 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// The tests which depend on time run in a synctest bubble, where time.Sleep
+// moves the clock forward instantly and exactly.
+
+func TestRefill(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		// One token every 6 seconds, up to 10.
+		l := New(Limit{Requests: 10, Per: time.Minute}, 100, 100)
+
+		for i := range 10 {
+			ok, _ := l.Allow("", "a")
+			if !ok {
+				t.Fatalf("request %d refused; want the whole burst allowed", i+1)
+			}
+		}
+
+		ok, retryAfter := l.Allow("", "a")
+		assert.Equal(t, ok, false)
+		assert.Equal(t, retryAfter, 6*time.Second)
+
+		// Part of the way to the next token.
+		time.Sleep(4 * time.Second)
+		ok, retryAfter = l.Allow("", "a")
+		assert.Equal(t, ok, false)
+		assert.Equal(t, retryAfter, 2*time.Second)
+
+		time.Sleep(2 * time.Second)
+		ok, _ = l.Allow("", "a")
+		assert.Equal(t, ok, true)
+		ok, _ = l.Allow("", "a")
+		assert.Equal(t, ok, false)
+
+		// Other clients have buckets of their own.
+		ok, _ = l.Allow("", "b")
+		assert.Equal(t, ok, true)
+
+		// After a whole period the bucket is full again, but no fuller.
+		time.Sleep(time.Hour)
+		for range 10 {
+			ok, _ = l.Allow("", "a")
+			assert.Equal(t, ok, true)
+		}
+		ok, _ = l.Allow("", "a")
+		assert.Equal(t, ok, false)
+	})
+}
+
+func TestDisabled(t *testing.T) {
+	l := New(Limit{}, 1, 1)
+	for range 100 {
+		ok, retryAfter := l.Allow("", "a")
+		assert.Equal(t, ok, true)
+		assert.Equal(t, retryAfter, time.Duration(0))
+	}
+	assert.Equal(t, l.Len(), 0)
+}
+
+func TestEviction(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		l := New(Limit{Requests: 2, Per: 10 * time.Second}, 2, 2)
+
+		// Two clients use up their buckets, filling the table.
+		for _, key := range []string{"a", "b"} {
+			l.Allow("", key)
+			l.Allow("", key)
+		}
+
+		// Neither is idle, so a new client is refused until the first of
+		// them will have refilled, and the others keep their state.
+		time.Sleep(time.Second)
+		ok, retryAfter := l.Allow("", "c")
+		assert.Equal(t, ok, false)
+		assert.Equal(t, retryAfter, 9*time.Second)
+		assert.Equal(t, l.Len(), 2)
+
+		ok, _ = l.Allow("", "a")
+		assert.Equal(t, ok, false)
+
+		// Once a bucket has refilled, its client can make way.
+		time.Sleep(10 * time.Second)
+		l.Allow("", "a")
+		ok, _ = l.Allow("", "c")
+		assert.Equal(t, ok, true)
+		assert.Equal(t, l.Len(), 2)
+
+		// b was the idle one, so a is still limited.
+		l.Allow("", "a")
+		ok, _ = l.Allow("", "a")
+		assert.Equal(t, ok, false)
+	})
+}
+
+// TestGroupLimit checks that one group filling its share of the table with
+// limited clients only turns away new clients from that group.
+func TestGroupLimit(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		l := New(Limit{Requests: 1, Per: 10 * time.Second}, 100, 3)
+
+		for _, key := range []string{"a1", "a2", "a3"} {
+			ok, _ := l.Allow("a", key)
+			assert.Equal(t, ok, true)
+		}
+
+		time.Sleep(4 * time.Second)
+		ok, retryAfter := l.Allow("a", "a4")
+		assert.Equal(t, ok, false)
+		assert.Equal(t, retryAfter, 6*time.Second)
+
+		// Other groups are unaffected.
+		for _, key := range []string{"b1", "b2", "b3"} {
+			ok, _ = l.Allow("b", key)
+			assert.Equal(t, ok, true)
+		}
+		assert.Equal(t, l.Len(), 6)
+
+		// Once one of group a's buckets has refilled, it makes way.
+		time.Sleep(6 * time.Second)
+		ok, _ = l.Allow("a", "a4")
+		assert.Equal(t, ok, true)
+		assert.Equal(t, l.Len(), 6)
+	})
+}
+
+// TestSaturation checks that filling the whole table takes as many groups as
+// it has room for, however many keys each group has.
+func TestSaturation(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		l := New(Limit{Requests: 1, Per: time.Hour}, 8, 2)
+
+		// One attacker group with plenty of keys only gets 2 slots.
+		allowed := 0
+		for i := range 100 {
+			if ok, _ := l.Allow("attacker", fmt.Sprint(i)); ok {
+				allowed++
+			}
+		}
+		assert.Equal(t, allowed, 2)
+		assert.Equal(t, l.Len(), 2)
+
+		// Everyone else still gets in until the table is full.
+		for i := range 6 {
+			visitor := fmt.Sprint("visitor", i)
+			ok, _ := l.Allow(visitor, visitor)
+			assert.Equal(t, ok, true)
+		}
+		ok, _ := l.Allow("late", "late")
+		assert.Equal(t, ok, false)
+	})
+}
+
+func TestCleanup(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		l := New(Limit{Requests: 2, Per: 10 * time.Second}, 100, 100)
+
+		l.Allow("", "a")
+		l.Allow("", "b")
+		l.Allow("", "b")
+		assert.Equal(t, l.Cleanup(), 0)
+
+		// a only needs one token back, b needs two.
+		time.Sleep(5 * time.Second)
+		assert.Equal(t, l.Cleanup(), 1)
+		assert.Equal(t, l.Len(), 1)
+
+		time.Sleep(5 * time.Second)
+		assert.Equal(t, l.Cleanup(), 1)
+		assert.Equal(t, l.Len(), 0)
+	})
+}
+
+func TestLimitText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Limit
+		wantStr string
+		wantErr bool
+	}{
+		{text: "100/10s", want: Limit{100, 10 * time.Second}, wantStr: "100/10s"},
+		{text: "10/15m", want: Limit{10, 15 * time.Minute}, wantStr: "10/15m"},
+		{text: "5/1h", want: Limit{5, time.Hour}, wantStr: "5/1h"},
+		{text: "off", wantStr: "off"},
+		{text: "0", wantStr: "off"},
+		{text: "100", wantErr: true},
+		{text: "0/10s", wantErr: true},
+		{text: "10/-1s", wantErr: true},
+		{text: "ten/1s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var l Limit
+			err := l.UnmarshalText([]byte(tt.text))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v; want an error", l)
+				}
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, l, tt.want)
+			assert.Equal(t, l.String(), tt.wantStr)
+		})
+	}
+}
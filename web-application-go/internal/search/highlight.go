@@ -0,0 +1,113 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Fragment is a piece of highlighted text. Fragments with Match set are the
+// words that matched the query. Keeping the text unescaped lets the caller
+// choose how to mark matches up (html/template escapes each piece).
+type Fragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match"`
+}
+
+// Highlight splits text into fragments, marking every word which matches one
+// of terms (as returned by Terms).
+func Highlight(text string, terms []string) []Fragment {
+	return fragments(text, 0, len(text), terms)
+}
+
+// Excerpt returns roughly maxChars characters of text centred on the first
+// word matching one of terms, as highlighted fragments. If nothing matches,
+// it returns the start of the text. An ellipsis marks text that was cut off.
+func Excerpt(text string, terms []string, maxChars int) []Fragment {
+	text = strings.Join(strings.Fields(text), " ")
+
+	if utf8.RuneCountInString(text) <= maxChars {
+		return Highlight(text, terms)
+	}
+
+	want := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		want[t] = true
+	}
+
+	// Find the first matching word, and start the window a third of the way
+	// before it so the match has some context on both sides.
+	first := 0
+	for _, t := range tokenize(text) {
+		if want[t.Term] {
+			first = t.Start
+			break
+		}
+	}
+
+	start := backRunes(text, first, maxChars/3)
+	end := forwardRunes(text, start, maxChars)
+
+	// Don't cut words in half.
+	if start > 0 {
+		if i := strings.IndexByte(text[start:end], ' '); i >= 0 && start+i < first {
+			start += i + 1
+		}
+	}
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+			end = start + i
+		}
+	}
+
+	frags := fragments(text, start, end, terms)
+	if start > 0 {
+		frags = append([]Fragment{{Text: "…"}}, frags...)
+	}
+	if end < len(text) {
+		frags = append(frags, Fragment{Text: "…"})
+	}
+	return frags
+}
+
+// fragments highlights text[start:end].
+func fragments(text string, start, end int, terms []string) []Fragment {
+	want := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		want[t] = true
+	}
+
+	var frags []Fragment
+	pos := start
+	for _, t := range tokenize(text[start:end]) {
+		if !want[t.Term] {
+			continue
+		}
+		if start+t.Start > pos {
+			frags = append(frags, Fragment{Text: text[pos : start+t.Start]})
+		}
+		frags = append(frags, Fragment{Text: text[start+t.Start : start+t.End], Match: true})
+		pos = start + t.End
+	}
+	if pos < end {
+		frags = append(frags, Fragment{Text: text[pos:end]})
+	}
+	return frags
+}
+
+// backRunes returns the byte offset n runes before offset i.
+func backRunes(s string, i, n int) int {
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return i
+}
+
+// forwardRunes returns the byte offset n runes after offset i.
+func forwardRunes(s string, i, n int) int {
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i
+}
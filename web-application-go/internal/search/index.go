@@ -0,0 +1,165 @@
+// Package search implements a small in-memory full-text index with BM25
+// ranking and highlighted excerpts. It knows nothing about snippets: callers
+// add and remove documents by integer ID.
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// titleWeight is how many times more a word in the title counts than a word
+// in the content.
+const titleWeight = 3
+
+// BM25 tuning parameters, with their usual values: k1 limits how much
+// repeating a term keeps adding to the score, and b controls how much long
+// documents are penalised.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type document struct {
+	length float64
+	terms  []string // distinct terms, so the postings can be cleaned up
+}
+
+// Index is an inverted index mapping each term to the documents that contain
+// it. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[int]float64 // term -> doc ID -> weighted frequency
+	docs     map[int]document
+	totalLen float64
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[int]float64),
+		docs:     make(map[int]document),
+	}
+}
+
+// Add indexes a document, replacing any previous version with the same ID.
+func (idx *Index) Add(id int, title, content string) {
+	freqs := make(map[string]float64)
+	var length float64
+
+	for _, t := range tokenize(title) {
+		freqs[t.Term] += titleWeight
+		length += titleWeight
+	}
+	for _, t := range tokenize(content) {
+		freqs[t.Term]++
+		length++
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+
+	doc := document{length: length, terms: make([]string, 0, len(freqs))}
+	for term, freq := range freqs {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[int]float64)
+		}
+		idx.postings[term][id] = freq
+		doc.terms = append(doc.terms, term)
+	}
+
+	idx.docs[id] = doc
+	idx.totalLen += length
+}
+
+// Remove deletes a document from the index. Removing an unknown ID does
+// nothing.
+func (idx *Index) Remove(id int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id int) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+
+	delete(idx.docs, id)
+	idx.totalLen -= doc.length
+}
+
+// Len returns the number of documents in the index.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+// Hit is a document matching a query, with its relevance score.
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+// Search returns every document containing at least one of the query's
+// terms, most relevant first. Documents matching more of the terms, matching
+// rarer terms or matching in the title score higher. Ties are broken by ID,
+// newest (highest) first.
+func (idx *Index) Search(query string) []Hit {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.docs))
+	if n == 0 {
+		return nil
+	}
+	avgLen := idx.totalLen / n
+
+	scores := make(map[int]float64)
+	for _, term := range terms {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for id, tf := range postings {
+			norm := 1 - bm25B + bm25B*idx.docs[id].length/avgLen
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+
+	return hits
+}
@@ -0,0 +1,268 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"ete", "ete"},
+		{"Été", "ete"},
+		{"ÉTÉ", "ete"},
+		{"été", "ete"}, // already decomposed
+		{"naïve", "naive"},
+		{"Noël", "noel"},
+		{"cœur", "coeur"},
+		{"Æsir", "aesir"},
+		{"Straße", "strasse"},
+		{"Go123", "go123"},
+		{"日本", "日本"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			assert.Equal(t, Normalize(tt.word), tt.want)
+		})
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"  --  ", nil},
+		{"go", []string{"go"}},
+		{"Été été ete", []string{"ete"}},
+		{"http.Server, net/http", []string{"http", "server", "net"}},
+		{"l'été à Paris", []string{"l", "ete", "a", "paris"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, strings.Join(Terms(tt.query), " "), strings.Join(tt.want, " "))
+		})
+	}
+}
+
+// hitIDs returns the IDs of the hits for query, in order.
+func hitIDs(idx *Index, query string) []int {
+	var ids []int
+	for _, h := range idx.Search(query) {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestSearchAccents(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "Été indien", "Une chanson.")
+	idx.Add(2, "Summer", "ete is summer in French")
+	idx.Add(3, "Winter", "hiver")
+
+	for _, query := range []string{"ete", "Été", "ÉTÉ", "été"} {
+		t.Run(query, func(t *testing.T) {
+			ids := hitIDs(idx, query)
+			slices.Sort(ids)
+			assert.Equal(t, slices.Equal(ids, []int{1, 2}), true)
+		})
+	}
+}
+
+func TestSearchTitleWeight(t *testing.T) {
+	idx := NewIndex()
+	// Both documents have the same length and contain "golang" once, but
+	// only the first has it in the title.
+	idx.Add(1, "golang", "notes")
+	idx.Add(2, "notes", "golang")
+
+	hits := idx.Search("golang")
+	assert.Equal(t, len(hits), 2)
+	assert.Equal(t, hits[0].ID, 1)
+	if hits[0].Score <= hits[1].Score {
+		t.Errorf("title match scored %v, content match %v", hits[0].Score, hits[1].Score)
+	}
+
+	// A title match outweighs the same word repeated in the content.
+	idx.Add(2, "notes", "golang golang")
+	assert.Equal(t, hitIDs(idx, "golang")[0], 1)
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "Parse", "read a config file")
+	idx.Add(2, "Parse", "read a config file")
+	idx.Add(3, "Other", "read something else")
+
+	// Matching more of the terms wins, and equal scores go newest first.
+	assert.Equal(t, slices.Equal(hitIDs(idx, "config read"), []int{2, 1, 3}), true)
+
+	assert.Equal(t, len(idx.Search("missing")), 0)
+	assert.Equal(t, len(idx.Search("  ")), 0)
+	assert.Equal(t, len(NewIndex().Search("read")), 0)
+}
+
+// assertConsistent checks that the postings, the documents and the total
+// length all agree, with no empty or orphaned entries left behind.
+func assertConsistent(t *testing.T, idx *Index) {
+	t.Helper()
+
+	var total float64
+	for id, doc := range idx.docs {
+		total += doc.length
+		for _, term := range doc.terms {
+			if _, ok := idx.postings[term][id]; !ok {
+				t.Errorf("document %d has no posting for %q", id, term)
+			}
+		}
+	}
+	assert.Equal(t, idx.totalLen, total)
+
+	for term, postings := range idx.postings {
+		if len(postings) == 0 {
+			t.Errorf("empty postings for %q", term)
+		}
+		for id := range postings {
+			if !slices.Contains(idx.docs[id].terms, term) {
+				t.Errorf("posting for %q points at document %d, which doesn't have it", term, id)
+			}
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "Go", "unique words")
+	idx.Add(2, "Go", "shared")
+	assertConsistent(t, idx)
+
+	idx.Remove(1)
+	assertConsistent(t, idx)
+	assert.Equal(t, idx.Len(), 1)
+	assert.Equal(t, len(idx.Search("unique")), 0)
+	if _, ok := idx.postings["unique"]; ok {
+		t.Error("postings for a removed document's only term were kept")
+	}
+	assert.Equal(t, len(idx.postings["go"]), 1)
+
+	// Removing an unknown ID does nothing.
+	idx.Remove(1)
+	idx.Remove(99)
+	assertConsistent(t, idx)
+	assert.Equal(t, idx.Len(), 1)
+
+	idx.Remove(2)
+	assertConsistent(t, idx)
+	assert.Equal(t, idx.Len(), 0)
+	assert.Equal(t, len(idx.postings), 0)
+	assert.Equal(t, idx.totalLen, 0.0)
+}
+
+func TestReAdd(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "Go", "old content")
+	idx.Add(2, "Rust", "other content")
+
+	// Adding an ID again replaces the document, dropping its old terms.
+	idx.Add(1, "Zig", "new text")
+	assertConsistent(t, idx)
+	assert.Equal(t, idx.Len(), 2)
+	assert.Equal(t, len(idx.Search("go old")), 0)
+	if _, ok := idx.postings["old"]; ok {
+		t.Error("postings for a replaced term were kept")
+	}
+	assert.Equal(t, slices.Equal(hitIDs(idx, "zig"), []int{1}), true)
+	assert.Equal(t, slices.Equal(hitIDs(idx, "content"), []int{2}), true)
+
+	// Removing and adding back gives the same scores as the first time.
+	before := idx.Search("content other")
+	idx.Remove(2)
+	idx.Add(2, "Rust", "other content")
+	assertConsistent(t, idx)
+	assert.Equal(t, slices.Equal(idx.Search("content other"), before), true)
+}
+
+// joinFragments returns the text of frags, with matches in brackets.
+func joinFragments(frags []Fragment) string {
+	var b strings.Builder
+	for _, f := range frags {
+		if f.Match {
+			b.WriteString("[" + f.Text + "]")
+		} else {
+			b.WriteString(f.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{"ASCII", "go is fun, Go!", "go", "[go] is fun, [Go]!"},
+		{"No match", "nothing here", "go", "nothing here"},
+		{"Accents", "L'été à Paris — très chaud", "ete tres", "L'[été] à Paris — [très] chaud"},
+		{"Decomposed accents", "un été chaud", "ete", "un [été] chaud"},
+		{"Ligature", "Le cœur, le coeur", "coeur", "Le [cœur], le [coeur]"},
+		{"Four-byte runes", "🙂 café 🙂 CAFÉ", "cafe", "🙂 [café] 🙂 [CAFÉ]"},
+		{"CJK", "日本 語 日本", "日本", "[日本] 語 [日本]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frags := Highlight(tt.text, Terms(tt.query))
+			assert.Equal(t, joinFragments(frags), tt.want)
+
+			// The fragments cover the text exactly, each on rune
+			// boundaries.
+			var all strings.Builder
+			for _, f := range frags {
+				if !utf8.ValidString(f.Text) {
+					t.Errorf("fragment %q isn't valid UTF-8", f.Text)
+				}
+				all.WriteString(f.Text)
+			}
+			assert.Equal(t, all.String(), tt.text)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		maxChars int
+		want     string
+	}{
+		{"Short", "un été  chaud", "ete", 20, "un [été] chaud"},
+		{"No match", "début du texte qui est bien trop long", "zzz", 16, "début du texte…"},
+		{"Match at the end", "à é î ô û ç ë ï ü ÿ œ æ Noël", "noel", 12, "…æ [Noël]"},
+		{"Match in the middle", "ééé ààà ççç ùùù 🙂 café 🙂 ààà ççç ùùù ééé", "cafe", 16, "…🙂 [café] 🙂 ààà…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frags := Excerpt(tt.text, Terms(tt.query), tt.maxChars)
+			assert.Equal(t, joinFragments(frags), tt.want)
+
+			for _, f := range frags {
+				if !utf8.ValidString(f.Text) {
+					t.Errorf("fragment %q isn't valid UTF-8", f.Text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// token is a word found in a piece of text. Start and End are byte offsets
+// into the original text, which is what highlighting needs; Term is the
+// normalised form used for matching.
+type token struct {
+	Start, End int
+	Term       string
+}
+
+// tokenize splits text into words (runs of letters and digits) and
+// normalises each one with Normalize.
+func tokenize(text string) []token {
+	var tokens []token
+
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			tokens = appendToken(tokens, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = appendToken(tokens, text, start, len(text))
+	}
+
+	return tokens
+}
+
+func appendToken(tokens []token, text string, start, end int) []token {
+	term := Normalize(text[start:end])
+	if term == "" {
+		return tokens
+	}
+	return append(tokens, token{Start: start, End: end, Term: term})
+}
+
+// ligatures are letters which Unicode doesn't decompose but which French
+// (and German) readers type either way.
+var ligatures = strings.NewReplacer(
+	"œ", "oe",
+	"æ", "ae",
+	"ß", "ss",
+)
+
+// Normalize lowercases a word and strips its accents, so that "Été", "été"
+// and "ete" all match each other. Accents are removed by decomposing the word
+// (NFD) and dropping the combining marks.
+func Normalize(word string) string {
+	word = ligatures.Replace(strings.ToLower(word))
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Terms returns the distinct normalised terms in a query, in the order they
+// first appear.
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+
+	for _, t := range tokenize(query) {
+		if !seen[t.Term] {
+			seen[t.Term] = true
+			terms = append(terms, t.Term)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,399 @@
+// Package sessions provides server-side HTTP sessions. A random token is sent
+// to the client in a cookie and the session data itself stays in a Store.
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type status int
+
+const (
+	unmodified status = iota
+	modified
+	destroyed
+)
+
+// session is the per-request state that the Manager keeps in the request
+// context.
+type session struct {
+	mu       sync.Mutex
+	token    string
+	deadline time.Time // absolute expiry, set when the session is created
+	values   map[string]any
+	status   status
+}
+
+// encoded is the on-disk (or in-memory) representation of a session.
+type encoded struct {
+	Deadline time.Time
+	Values   map[string]any
+}
+
+// Manager loads and saves sessions for each request and gives handlers
+// access to them through the request context.
+type Manager struct {
+	// Store holds the session data.
+	Store Store
+	// IdleTimeout is how long a session may go unused before it expires.
+	// Every request that loads the session pushes the expiry back. Zero
+	// disables the idle timeout.
+	IdleTimeout time.Duration
+	// Lifetime is the absolute maximum age of a session, however active it
+	// is.
+	Lifetime time.Duration
+	// Cookie holds the settings for the session cookie. Its Value and
+	// Expires fields are ignored.
+	Cookie http.Cookie
+	// ErrorFunc is called when loading or saving a session fails. It must
+	// send a response.
+	ErrorFunc func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// New returns a Manager with sensible defaults: a 24 hour lifetime, no idle
+// timeout, and an HttpOnly, SameSite=Lax cookie named "session".
+func New(store Store) *Manager {
+	return &Manager{
+		Store:    store,
+		Lifetime: 24 * time.Hour,
+		Cookie: http.Cookie{
+			Name:     "session",
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		ErrorFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		},
+	}
+}
+
+type contextKey struct{}
+
+// LoadAndSave is middleware which loads the session for the request (or
+// starts a new empty one) and saves any changes before the response headers
+// are written.
+func (m *Manager) LoadAndSave(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Cookie")
+
+		var token string
+		if cookie, err := r.Cookie(m.Cookie.Name); err == nil {
+			token = cookie.Value
+		}
+
+		s, err := m.load(token)
+		if err != nil {
+			m.ErrorFunc(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, s)
+		sw := &sessionWriter{ResponseWriter: w, manager: m, request: r, session: s}
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		// Make sure the session is saved even if the handler didn't write
+		// anything.
+		if !sw.written {
+			sw.commit()
+		}
+	})
+}
+
+func (m *Manager) load(token string) (*session, error) {
+	s := &session{values: make(map[string]any)}
+	if token == "" {
+		return s, nil
+	}
+
+	b, found, err := m.Store.Find(token)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return s, nil
+	}
+
+	var e encoded
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&e)
+	if err != nil {
+		return nil, err
+	}
+
+	s.token = token
+	s.deadline = e.Deadline
+	if e.Values != nil {
+		s.values = e.Values
+	}
+
+	// With an idle timeout, every request moves the expiry forward, so the
+	// session has to be saved again even if nothing in it changed.
+	if m.IdleTimeout > 0 {
+		s.status = modified
+	}
+
+	return s, nil
+}
+
+// save writes the session to the store (or deletes it) and sets the cookie
+// on the response as needed.
+func (m *Manager) save(w http.ResponseWriter, s *session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.status {
+	case destroyed:
+		m.setCookie(w, "", time.Time{})
+		return nil
+	case unmodified:
+		return nil
+	}
+
+	if s.token == "" {
+		token, err := generateToken()
+		if err != nil {
+			return err
+		}
+		s.token = token
+	}
+	if s.deadline.IsZero() {
+		s.deadline = time.Now().Add(m.Lifetime)
+	}
+
+	expiry := s.deadline
+	if m.IdleTimeout > 0 {
+		if idle := time.Now().Add(m.IdleTimeout); idle.Before(expiry) {
+			expiry = idle
+		}
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(encoded{Deadline: s.deadline, Values: s.values})
+	if err != nil {
+		return err
+	}
+
+	err = m.Store.Commit(s.token, buf.Bytes(), expiry)
+	if err != nil {
+		return err
+	}
+
+	m.setCookie(w, s.token, expiry)
+	return nil
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, token string, expiry time.Time) {
+	cookie := m.Cookie
+	cookie.Value = token
+
+	if token == "" {
+		cookie.Expires = time.Unix(1, 0)
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expiry.UTC()
+		cookie.MaxAge = int(time.Until(expiry).Seconds() + 1)
+	}
+
+	w.Header().Add("Set-Cookie", cookie.String())
+	w.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sessionWriter saves the session just before the response headers are
+// written, which is the last moment a cookie can still be set.
+type sessionWriter struct {
+	http.ResponseWriter
+	manager *Manager
+	request *http.Request
+	session *session
+	written bool
+	failed  bool
+}
+
+func (sw *sessionWriter) commit() {
+	sw.written = true
+	err := sw.manager.save(sw.ResponseWriter, sw.session)
+	if err != nil {
+		sw.failed = true
+		sw.manager.ErrorFunc(sw.ResponseWriter, sw.request, err)
+	}
+}
+
+func (sw *sessionWriter) WriteHeader(status int) {
+	if !sw.written {
+		sw.commit()
+	}
+	if sw.failed {
+		return
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+	if !sw.written {
+		sw.commit()
+	}
+	if sw.failed {
+		// The error response has already been sent; drop the handler's
+		// output rather than appending it to the error page.
+		return len(b), nil
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *sessionWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+var errNoSession = errors.New("sessions: no session in request context; is LoadAndSave middleware in use?")
+
+func (m *Manager) session(ctx context.Context) *session {
+	s, ok := ctx.Value(contextKey{}).(*session)
+	if !ok {
+		panic(errNoSession)
+	}
+	return s
+}
+
+// Put adds a key and value to the session, replacing any existing value.
+// Values must be types that encoding/gob can encode; custom types need
+// registering with gob.Register.
+func (m *Manager) Put(ctx context.Context, key string, val any) {
+	s := m.session(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = val
+	s.status = modified
+}
+
+// Get returns the value for key, or nil if there isn't one.
+func (m *Manager) Get(ctx context.Context, key string) any {
+	s := m.session(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.values[key]
+}
+
+// Pop returns the value for key and removes it from the session, which makes
+// it useful for one-time messages. It returns nil if there is no value.
+func (m *Manager) Pop(ctx context.Context, key string) any {
+	s := m.session(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.values[key]
+	if !ok {
+		return nil
+	}
+	delete(s.values, key)
+	s.status = modified
+	return val
+}
+
+// Remove deletes the value for key from the session.
+func (m *Manager) Remove(ctx context.Context, key string) {
+	s := m.session(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.status = modified
+	}
+}
+
+// Exists reports whether the session holds a value for key.
+func (m *Manager) Exists(ctx context.Context, key string) bool {
+	s := m.session(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.values[key]
+	return ok
+}
+
+// GetString returns the value for key as a string, or "" if there is no
+// value or it isn't a string.
+func (m *Manager) GetString(ctx context.Context, key string) string {
+	str, _ := m.Get(ctx, key).(string)
+	return str
+}
+
+// GetInt returns the value for key as an int, or 0 if there is no value or
+// it isn't an int.
+func (m *Manager) GetInt(ctx context.Context, key string) int {
+	i, _ := m.Get(ctx, key).(int)
+	return i
+}
+
+// PopString is the string version of Pop.
+func (m *Manager) PopString(ctx context.Context, key string) string {
+	str, _ := m.Pop(ctx, key).(string)
+	return str
+}
+
+// RenewToken gives the session a new token while keeping its data, and
+// deletes the old token from the store. Call it whenever the privilege level
+// changes (logging in or out) to prevent session fixation attacks.
+func (m *Manager) RenewToken(ctx context.Context) error {
+	s := m.session(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" {
+		err := m.Store.Delete(s.token)
+		if err != nil {
+			return err
+		}
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	s.token = token
+	s.deadline = time.Now().Add(m.Lifetime)
+	s.status = modified
+	return nil
+}
+
+// Destroy deletes the session from the store and expires the cookie. The
+// session is empty for the rest of the request.
+func (m *Manager) Destroy(ctx context.Context) error {
+	s := m.session(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" {
+		err := m.Store.Delete(s.token)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.token = ""
+	s.values = make(map[string]any)
+	s.status = destroyed
+	return nil
+}
@@ -0,0 +1,238 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+// The tests which depend on time run in a synctest bubble, where time.Sleep
+// moves the clock forward instantly.
+
+// newTestHandler returns a handler for m with a route for each session
+// operation. Each writes the value it read, if any.
+func newTestHandler(m *Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		m.Put(r.Context(), "key", r.URL.Query().Get("value"))
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(m.GetString(r.Context(), "key")))
+	})
+	mux.HandleFunc("/pop", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(m.PopString(r.Context(), "key")))
+	})
+	mux.HandleFunc("/renew", func(w http.ResponseWriter, r *http.Request) {
+		err := m.RenewToken(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/destroy", func(w http.ResponseWriter, r *http.Request) {
+		err := m.Destroy(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	return m.LoadAndSave(mux)
+}
+
+// testBrowser sends requests to a handler and keeps the session cookie it
+// is given, as a browser would.
+type testBrowser struct {
+	t      *testing.T
+	h      http.Handler
+	cookie *http.Cookie
+}
+
+// do requests path and returns the response body.
+func (b *testBrowser) do(path string) string {
+	b.t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if b.cookie != nil {
+		r.AddCookie(b.cookie)
+	}
+	rr := httptest.NewRecorder()
+	b.h.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusOK {
+		b.t.Fatalf("%s: got status %d", path, rr.Code)
+	}
+	for _, c := range rr.Result().Cookies() {
+		if c.MaxAge < 0 {
+			b.cookie = nil
+		} else {
+			b.cookie = c
+		}
+	}
+	return rr.Body.String()
+}
+
+func TestIdleTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		m := New(NewMemoryStore())
+		m.Lifetime = 24 * time.Hour
+		m.IdleTimeout = time.Hour
+		b := &testBrowser{t: t, h: newTestHandler(m)}
+
+		b.do("/put?value=a")
+		assert.Equal(t, b.cookie.Expires, time.Now().Add(time.Hour).UTC())
+
+		// Each request pushes the expiry back.
+		for range 3 {
+			time.Sleep(59 * time.Minute)
+			assert.Equal(t, b.do("/get"), "a")
+		}
+
+		time.Sleep(time.Hour + time.Second)
+		assert.Equal(t, b.do("/get"), "")
+	})
+}
+
+func TestLifetime(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		m := New(NewMemoryStore())
+		m.Lifetime = 3 * time.Hour
+		m.IdleTimeout = time.Hour
+		b := &testBrowser{t: t, h: newTestHandler(m)}
+
+		start := time.Now()
+		b.do("/put?value=a")
+
+		// However active the session is, it ends after its lifetime.
+		for range 5 {
+			time.Sleep(30 * time.Minute)
+			assert.Equal(t, b.do("/get"), "a")
+		}
+		assert.Equal(t, b.cookie.Expires, start.Add(3*time.Hour).UTC())
+
+		time.Sleep(30*time.Minute + time.Second)
+		assert.Equal(t, b.do("/get"), "")
+	})
+}
+
+func TestRenewToken(t *testing.T) {
+	b := &testBrowser{t: t, h: newTestHandler(New(NewMemoryStore()))}
+
+	b.do("/put?value=a")
+	old := b.cookie
+
+	b.do("/renew")
+	if b.cookie.Value == old.Value {
+		t.Fatal("RenewToken kept the old token")
+	}
+	assert.Equal(t, b.do("/get"), "a")
+
+	// Whoever still has the old token gets an empty session.
+	stale := &testBrowser{t: t, h: b.h, cookie: old}
+	assert.Equal(t, stale.do("/get"), "")
+}
+
+func TestPop(t *testing.T) {
+	b := &testBrowser{t: t, h: newTestHandler(New(NewMemoryStore()))}
+
+	b.do("/put?value=flash")
+	assert.Equal(t, b.do("/pop"), "flash")
+	assert.Equal(t, b.do("/pop"), "")
+	assert.Equal(t, b.do("/get"), "")
+}
+
+func TestDestroy(t *testing.T) {
+	b := &testBrowser{t: t, h: newTestHandler(New(NewMemoryStore()))}
+
+	b.do("/put?value=a")
+	old := b.cookie
+
+	b.do("/destroy")
+	if b.cookie != nil {
+		t.Fatal("the cookie wasn't expired")
+	}
+
+	stale := &testBrowser{t: t, h: b.h, cookie: old}
+	assert.Equal(t, stale.do("/get"), "")
+}
+
+// TestUnmodified checks that a session nothing was stored in isn't saved,
+// so visitors who only read pages don't fill the store.
+func TestUnmodified(t *testing.T) {
+	store := NewMemoryStore()
+	b := &testBrowser{t: t, h: newTestHandler(New(store))}
+
+	b.do("/get")
+	if b.cookie != nil {
+		t.Error("got a cookie for an empty session")
+	}
+	assert.Equal(t, len(store.items), 0)
+}
+
+// TestFileStoreRestart checks that sessions in a FileStore survive a restart:
+// a new Manager on the same directory loads what the first one saved.
+func TestFileStoreRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir)
+	assert.NilError(t, err)
+	b := &testBrowser{t: t, h: newTestHandler(New(store))}
+	b.do("/put?value=kept")
+
+	store, err = NewFileStore(dir)
+	assert.NilError(t, err)
+	b.h = newTestHandler(New(store))
+	assert.Equal(t, b.do("/get"), "kept")
+}
+
+func TestStores(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	assert.NilError(t, err)
+
+	stores := []struct {
+		name  string
+		store interface {
+			Store
+			Cleanup() (int, error)
+		}
+	}{
+		{"Memory", NewMemoryStore()},
+		{"File", fileStore},
+	}
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.store
+			now := time.Now()
+
+			assert.NilError(t, s.Commit("live", []byte("data"), now.Add(time.Hour)))
+			assert.NilError(t, s.Commit("expired", []byte("old"), now.Add(-time.Second)))
+			assert.NilError(t, s.Commit("expired too", []byte("old"), now.Add(-time.Second)))
+
+			b, found, err := s.Find("live")
+			assert.NilError(t, err)
+			assert.Equal(t, found, true)
+			assert.Equal(t, string(b), "data")
+
+			_, found, err = s.Find("expired")
+			assert.NilError(t, err)
+			assert.Equal(t, found, false)
+
+			_, found, err = s.Find("never committed")
+			assert.NilError(t, err)
+			assert.Equal(t, found, false)
+
+			// "expired" was removed when it was looked up.
+			removed, err := s.Cleanup()
+			assert.NilError(t, err)
+			assert.Equal(t, removed, 1)
+
+			assert.NilError(t, s.Delete("live"))
+			assert.NilError(t, s.Delete("live"))
+			_, found, err = s.Find("live")
+			assert.NilError(t, err)
+			assert.Equal(t, found, false)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package sessions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store is the interface for the server-side session backends. Tokens are
+// opaque random strings; b is the encoded session data, which the store
+// doesn't need to understand.
+type Store interface {
+	// Find returns the data for a session token. If the token doesn't exist
+	// or has expired, found is false.
+	Find(token string) (b []byte, found bool, err error)
+	// Commit adds or replaces the data for a session token, which should be
+	// treated as expired after the given time.
+	Commit(token string, b []byte, expiry time.Time) error
+	// Delete removes a session token. Deleting a token that doesn't exist is
+	// not an error.
+	Delete(token string) error
+}
+
+type memoryItem struct {
+	data   []byte
+	expiry time.Time
+}
+
+// MemoryStore keeps sessions in a map, so they are lost when the process
+// exits. Expired sessions are removed lazily when they are looked up, and in
+// bulk by Cleanup.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+}
+
+// NewMemoryStore returns an empty in-memory session store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem)}
+}
+
+func (s *MemoryStore) Find(token string) ([]byte, bool, error) {
+	s.mu.RLock()
+	item, ok := s.items[token]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(item.expiry) {
+		s.Delete(token)
+		return nil, false, nil
+	}
+	return item.data, true, nil
+}
+
+func (s *MemoryStore) Commit(token string, b []byte, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[token] = memoryItem{data: b, expiry: expiry}
+	return nil
+}
+
+func (s *MemoryStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, token)
+	return nil
+}
+
+// Cleanup removes every expired session and returns how many were removed.
+func (s *MemoryStore) Cleanup() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for token, item := range s.items {
+		if now.After(item.expiry) {
+			delete(s.items, token)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// FileStore keeps each session in its own file inside a directory, so
+// sessions survive a restart. File names are a hash of the token, which means
+// someone who can list the directory still can't recover valid tokens.
+type FileStore struct {
+	dir string
+}
+
+type fileItem struct {
+	Data   []byte    `json:"data"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// NewFileStore returns a store which writes session files into dir, creating
+// the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".session")
+}
+
+func (s *FileStore) Find(token string) ([]byte, bool, error) {
+	b, err := os.ReadFile(s.path(token))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var item fileItem
+	err = json.Unmarshal(b, &item)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if time.Now().After(item.Expiry) {
+		return nil, false, s.Delete(token)
+	}
+	return item.Data, true, nil
+}
+
+// Commit writes the session to a temporary file and renames it into place,
+// so a concurrent Find never sees a half-written file.
+func (s *FileStore) Commit(token string, b []byte, expiry time.Time) error {
+	data, err := json.Marshal(fileItem{Data: b, Expiry: expiry})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "commit-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(token))
+}
+
+func (s *FileStore) Delete(token string) error {
+	err := os.Remove(s.path(token))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Cleanup removes every expired session file and returns how many were
+// removed.
+func (s *FileStore) Cleanup() (int, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.session"))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var item fileItem
+		if json.Unmarshal(b, &item) == nil && now.Before(item.Expiry) {
+			continue
+		}
+		if os.Remove(path) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
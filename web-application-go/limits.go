@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-application.antoine.example/internal/ratelimit"
+)
+
+// rateLimitClients is the most clients each limiter keeps track of. A bucket
+// is small, so this bounds the memory used by rate limiting to a few MB no
+// matter how many addresses requests come from.
+//
+// rateLimitClientsPerNetwork is the most of them which may share an IPv6
+// /48, the size of network a site is usually given. Anyone with a /48 has
+// 65,536 /64s to send from; without this cap they could fill a limiter with
+// clients which are all being limited, and lock every new client out until
+// those buckets refilled (15 minutes for logins).
+const (
+	rateLimitClients           = 100_000
+	rateLimitClientsPerNetwork = 256
+)
+
+// rateLimiters holds a limiter for each kind of request. Reads and writes are
+// limited separately so that browsing can't use up a client's allowance for
+// creating snippets, and login attempts have a much stricter limit of their
+// own to slow down password guessing.
+type rateLimiters struct {
+	read  *ratelimit.Limiter
+	write *ratelimit.Limiter
+	login *ratelimit.Limiter
+}
+
+func newRateLimiters(cfg config) *rateLimiters {
+	return &rateLimiters{
+		read:  ratelimit.New(cfg.rateLimit.read, rateLimitClients, rateLimitClientsPerNetwork),
+		write: ratelimit.New(cfg.rateLimit.write, rateLimitClients, rateLimitClientsPerNetwork),
+		login: ratelimit.New(cfg.rateLimit.login, rateLimitClients, rateLimitClientsPerNetwork),
+	}
+}
+
+// cleanup forgets idle clients in every limiter and returns how many there
+// were.
+func (rl *rateLimiters) cleanup() int {
+	return rl.read.Cleanup() + rl.write.Cleanup() + rl.login.Cleanup()
+}
+
+// clientIP returns the address of the connection a request came in on.
+// X-Forwarded-For is ignored, since any client can set it. Behind a reverse
+// proxy every request would share the proxy's limit, so the proxy should do
+// the rate limiting instead.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ipv6PrefixBits is the size of the IPv6 network treated as one client. An
+// ISP typically hands each customer at least a /64, so anyone can pick from
+// billions of addresses within one; limiting each address separately would
+// limit nobody. ipv6NetworkBits is the size of the network clients are
+// grouped by, for rateLimitClientsPerNetwork.
+const (
+	ipv6PrefixBits  = 64
+	ipv6NetworkBits = 48
+)
+
+// clientKey returns the key requests are rate limited by, and the group it
+// belongs to. For IPv4 both are the client's address. For IPv6 the key is
+// the /64 network of its address (as in "2001:db8:1:2::/64") and the group
+// the /48 ("2001:db8:1::/48").
+func clientKey(r *http.Request) (group, key string) {
+	ip := clientIP(r)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip, ip
+	}
+
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return addr.String(), addr.String()
+	}
+
+	addr = addr.WithZone("")
+	prefix, err := addr.Prefix(ipv6PrefixBits)
+	if err != nil {
+		return ip, ip
+	}
+	network, err := addr.Prefix(ipv6NetworkBits)
+	if err != nil {
+		return ip, ip
+	}
+	return network.String(), prefix.String()
+}
+
+// limitRequests applies the read limit to safe requests (GET, HEAD and the
+// like) and the write limit to everything else.
+func (app *application) limitRequests(next http.Handler) http.Handler {
+	reads := app.rateLimit(app.limiters.read, next)
+	writes := app.rateLimit(app.limiters.write, next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			reads.ServeHTTP(w, r)
+		default:
+			writes.ServeHTTP(w, r)
+		}
+	})
+}
+
+// limitLogins applies the login limit. It is used on top of limitRequests
+// for the login form, so a login attempt counts against both.
+func (app *application) limitLogins(next http.Handler) http.Handler {
+	return app.rateLimit(app.limiters.login, next)
+}
+
+// rateLimit sends a 429 Too Many Requests response, with a Retry-After
+// header, to clients which have used up their allowance with l.
+func (app *application) rateLimit(l *ratelimit.Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, retryAfter := l.Allow(clientKey(r))
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Retry-After is in whole seconds, so round up: retrying early
+		// would only be refused again.
+		seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+		// Debug rather than warning level, since a flood of requests
+		// would otherwise become a flood of log lines.
+		app.logger.Debug("rate limited", "ip", clientIP(r), "method", r.Method, "uri", r.URL.RequestURI(),
+			"limit", l.Limit().String(), "retry_after", retryAfter.Round(time.Millisecond))
+
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.writeProblem(w, r, problem{
+				Status: http.StatusTooManyRequests,
+				Detail: fmt.Sprintf("Rate limit of %s exceeded. Try again in %d seconds.", l.Limit(), seconds),
+			})
+			return
+		}
+		app.clientError(w, http.StatusTooManyRequests)
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"web-application.antoine.example/internal/assert"
+	"web-application.antoine.example/internal/ratelimit"
+)
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		wantGroup  string
+		wantKey    string
+	}{
+		{"192.0.2.1:1234", "192.0.2.1", "192.0.2.1"},
+		{"[::ffff:192.0.2.1]:1234", "192.0.2.1", "192.0.2.1"},
+		{"[2001:db8:1:2:3:4:5:6]:1234", "2001:db8:1::/48", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:2:ffff:ffff:ffff:ffff]:1234", "2001:db8:1::/48", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:ffff::1]:1234", "2001:db8:1::/48", "2001:db8:1:ffff::/64"},
+		{"[fe80::1%eth0]:1234", "fe80::/48", "fe80::/64"},
+		{"not an address", "not an address", "not an address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			group, key := clientKey(r)
+			assert.Equal(t, group, tt.wantGroup)
+			assert.Equal(t, key, tt.wantKey)
+		})
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name            string
+		path            string
+		wantContentType string
+	}{
+		{"HTML", "/snippet/view/1", "text/plain; charset=utf-8"},
+		{"API", "/api/v1/snippets/1", "application/problem+json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				// One token every 4 seconds.
+				h := app.rateLimit(ratelimit.New(ratelimit.Limit{Requests: 2, Per: 8 * time.Second}, 10, 10), next)
+
+				get := func() *http.Response {
+					rr := httptest.NewRecorder()
+					h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+					return rr.Result()
+				}
+
+				assert.Equal(t, get().StatusCode, http.StatusOK)
+				assert.Equal(t, get().StatusCode, http.StatusOK)
+
+				res := get()
+				assert.Equal(t, res.StatusCode, http.StatusTooManyRequests)
+				assert.Equal(t, res.Header.Get("Retry-After"), "4")
+				assert.Equal(t, res.Header.Get("Content-Type"), tt.wantContentType)
+
+				// Partial seconds are rounded up.
+				time.Sleep(1500 * time.Millisecond)
+				res = get()
+				assert.Equal(t, res.StatusCode, http.StatusTooManyRequests)
+				assert.Equal(t, res.Header.Get("Retry-After"), "3")
+
+				time.Sleep(2500 * time.Millisecond)
+				assert.Equal(t, get().StatusCode, http.StatusOK)
+			})
+		})
+	}
+}
+
+// TestRateLimitIPv6Network checks that addresses in the same /64 share a
+// limit.
+func TestRateLimitIPv6Network(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	h := app.rateLimit(ratelimit.New(ratelimit.Limit{Requests: 1, Per: time.Hour}, 10, 10),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	get := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	assert.Equal(t, get("[2001:db8:1:2::1]:1234"), http.StatusOK)
+	assert.Equal(t, get("[2001:db8:1:2::2]:1234"), http.StatusTooManyRequests)
+	assert.Equal(t, get("[2001:db8:1:3::1]:1234"), http.StatusOK)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"web-application.antoine.example/internal/models"
+	"web-application.antoine.example/internal/validator"
+)
+
+// The home page and GET /api/v1/snippets show the same listing: the newest
+// unexpired snippets, optionally narrowed down to one author or one tag, a
+// page at a time. Pages are addressed by a cursor rather than a page number,
+// so a snippet created while someone is paging doesn't push an item they
+// have already seen onto the next page.
+
+// homePageSize is the number of snippets per page on the home page.
+const homePageSize = 10
+
+// listItem is a snippet in a listing, with its author's name looked up.
+type listItem struct {
+	Snippet models.Snippet
+	Author  string
+}
+
+// snippetListing is one page of the snippet listing.
+type snippetListing struct {
+	Items []listItem
+	// Filter is the query that produced the page. Its Before and Limit
+	// fields are cleared, so that it only holds the filters.
+	Filter models.ListQuery
+	// AuthorName is the name of the author being filtered on, if any.
+	AuthorName string
+	// Cursor is the cursor the page was requested with, and Next the one for
+	// the page after it. Either is empty if there is no such page.
+	Cursor string
+	Next   string
+}
+
+// url returns the home page link for the listing with the given cursor,
+// keeping the current filters.
+func (l snippetListing) url(cursor string) string {
+	v := url.Values{}
+	if l.Filter.AuthorID != 0 {
+		v.Set("author", strconv.Itoa(l.Filter.AuthorID))
+	}
+	if l.Filter.Tag != "" {
+		v.Set("tag", l.Filter.Tag)
+	}
+	if cursor != "" {
+		v.Set("cursor", cursor)
+	}
+	if len(v) == 0 {
+		return "/"
+	}
+	return "/?" + v.Encode()
+}
+
+// FirstURL links to the first page of the listing.
+func (l snippetListing) FirstURL() string { return l.url("") }
+
+// NextURL links to the page after this one.
+func (l snippetListing) NextURL() string { return l.url(l.Next) }
+
+// encodeCursor and decodeCursor convert between a snippet ID and the opaque
+// cursor handed to clients. Clients should treat cursors as opaque strings,
+// which leaves room to change what they contain.
+func encodeCursor(before int) string {
+	if before == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte("before:" + strconv.Itoa(before)))
+}
+
+func decodeCursor(cursor string) (int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	v, ok := strings.CutPrefix(string(b), "before:")
+	if !ok {
+		return 0, false
+	}
+	before, err := strconv.Atoi(v)
+	if err != nil || before < 1 {
+		return 0, false
+	}
+	return before, true
+}
+
+// parseListQuery reads the author, tag and cursor query string parameters.
+// Any invalid values are reported in the returned map, keyed by parameter
+// name. The caller sets the Limit.
+func parseListQuery(r *http.Request) (models.ListQuery, map[string]string) {
+	var q models.ListQuery
+	errs := map[string]string{}
+	params := r.URL.Query()
+
+	if v := params.Get("author"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			errs["author"] = "must be a positive integer"
+		}
+		q.AuthorID = id
+	}
+	if v := params.Get("tag"); v != "" {
+		q.Tag = strings.ToLower(v)
+		if !validator.Matches(q.Tag, tagRX) || !validator.MaxChars(q.Tag, maxTagLength) {
+			errs["tag"] = "must be a valid tag"
+		}
+	}
+	if v := params.Get("cursor"); v != "" {
+		before, ok := decodeCursor(v)
+		if !ok {
+			errs["cursor"] = "must be a cursor returned by a previous request"
+		}
+		q.Before = before
+	}
+
+	return q, errs
+}
+
+// listSnippets runs a listing query and looks up the author of each snippet.
+func (app *application) listSnippets(q models.ListQuery) (snippetListing, error) {
+	snippets, next, err := app.snippets.List(q)
+	if err != nil {
+		return snippetListing{}, err
+	}
+
+	listing := snippetListing{
+		Items:  make([]listItem, len(snippets)),
+		Cursor: encodeCursor(q.Before),
+		Next:   encodeCursor(next),
+	}
+	listing.Filter = q
+	listing.Filter.Before, listing.Filter.Limit = 0, 0
+
+	// A page usually holds several snippets by the same few people, so
+	// remember the names already fetched.
+	names := map[int]string{}
+	for i, s := range snippets {
+		name, ok := names[s.AuthorID]
+		if !ok {
+			name, err = app.authorName(s.AuthorID)
+			if err != nil {
+				return snippetListing{}, err
+			}
+			names[s.AuthorID] = name
+		}
+		listing.Items[i] = listItem{Snippet: s, Author: name}
+	}
+
+	if q.AuthorID != 0 {
+		listing.AuthorName, err = app.authorName(q.AuthorID)
+		if err != nil {
+			return snippetListing{}, err
+		}
+	}
+
+	return listing, nil
+}
+
+// authorName returns the name of the user with the given ID. Snippets
+// created before user accounts existed have no author, and an account that
+// no longer exists is treated the same way.
+func (app *application) authorName(id int) (string, error) {
+	if id == 0 {
+		return "anonymous", nil
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return "anonymous", nil
+		}
+		return "", fmt.Errorf("looking up author %d: %w", id, err)
+	}
+	return user.Name, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+	"web-application.antoine.example/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, before := range []int{1, 42, 1 << 40} {
+		got, ok := decodeCursor(encodeCursor(before))
+		assert.Equal(t, ok, true)
+		assert.Equal(t, got, before)
+	}
+	assert.Equal(t, encodeCursor(0), "")
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+	client := newTestClient(t, ts)
+
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	cursors := []struct {
+		name   string
+		cursor string
+	}{
+		{"Not base64", "!!!"},
+		{"Padded", base64.URLEncoding.EncodeToString([]byte("before:3"))},
+		{"Wrong prefix", raw("after:3")},
+		{"No ID", raw("before:")},
+		{"Not a number", raw("before:x")},
+		{"Zero", raw("before:0")},
+		{"Negative", raw("before:-3")},
+		{"Trailing data", raw("before:3;drop")},
+	}
+
+	for _, tt := range cursors {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "?cursor=" + url.QueryEscape(tt.cursor)
+
+			res, _ := client.get("/" + query)
+			assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+
+			res, body := client.get("/api/v1/snippets" + query)
+			assert.Equal(t, res.StatusCode, http.StatusBadRequest)
+			p := readProblem(t, res, body)
+			assert.Equal(t, p.Errors["cursor"], "must be a cursor returned by a previous request")
+		})
+	}
+}
+
+// listPage is the body of GET /api/v1/snippets.
+type listPage struct {
+	Snippets []snippetJSON `json:"snippets"`
+	Metadata struct {
+		PageSize   int     `json:"page_size"`
+		NextCursor *string `json:"next_cursor"`
+	} `json:"metadata"`
+}
+
+// listAll pages through the API listing from the start, returning the IDs on
+// each page.
+func (c *testClient) listAll(pageSize int) [][]int {
+	c.t.Helper()
+
+	var pages [][]int
+	cursor := ""
+	for {
+		page := c.listPage(pageSize, cursor)
+		pages = append(pages, snippetIDs(page))
+		if page.Metadata.NextCursor == nil {
+			return pages
+		}
+		cursor = *page.Metadata.NextCursor
+		if len(pages) > 100 {
+			c.t.Fatal("the listing never ends")
+		}
+	}
+}
+
+func (c *testClient) listPage(pageSize int, cursor string) listPage {
+	c.t.Helper()
+
+	path := fmt.Sprintf("/api/v1/snippets?page_size=%d", pageSize)
+	if cursor != "" {
+		path += "&cursor=" + url.QueryEscape(cursor)
+	}
+	res, body := c.get(path)
+	if res.StatusCode != http.StatusOK {
+		c.t.Fatalf("%s: got status %d: %s", path, res.StatusCode, body)
+	}
+
+	var page listPage
+	err := json.Unmarshal([]byte(body), &page)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return page
+}
+
+func snippetIDs(page listPage) []int {
+	ids := []int{}
+	for _, s := range page.Snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// insertSnippets adds n snippets to app's storage.
+func insertSnippets(t *testing.T, app *application, n int) {
+	t.Helper()
+
+	for i := range n {
+		_, err := app.snippets.Insert(models.NewSnippet{Title: fmt.Sprintf("Snippet %d", i), Content: "content", Expires: 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListLastPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippets int
+		want     [][]int
+	}{
+		{"Empty", 0, [][]int{{}}},
+		{"One page", 2, [][]int{{2, 1}}},
+		{"Part-full last page", 5, [][]int{{5, 4}, {3, 2}, {1}}},
+		// A full last page mustn't lead to an empty one.
+		{"Full last page", 4, [][]int{{4, 3}, {2, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, routes := newTestApplication(t)
+			ts := newTestServer(t, routes)
+			insertSnippets(t, app, tt.snippets)
+
+			got := newTestClient(t, ts).listAll(2)
+			assert.Equal(t, fmt.Sprint(got), fmt.Sprint(tt.want))
+		})
+	}
+}
+
+// TestListHomePageLinks checks that the home page only links to an older
+// page when there is one.
+func TestListHomePageLinks(t *testing.T) {
+	app, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+	insertSnippets(t, app, homePageSize+1)
+	client := newTestClient(t, ts)
+
+	_, body := client.get("/")
+	next := encodeCursor(2)
+	assert.StringContains(t, body, `<a href="/?cursor=`+next+`">Older</a>`)
+
+	_, body = client.get("/?cursor=" + next)
+	assert.StringContains(t, body, "Snippet 0")
+	assert.StringContains(t, body, `<a href="/">Newest</a>`)
+	if strings.Contains(body, ">Older</a>") {
+		t.Error("the last page links to an older one")
+	}
+}
+
+// TestListStableOrder checks that snippets created while a client is paging
+// don't shift the pages it hasn't read yet, and that deleted ones just drop
+// out.
+func TestListStableOrder(t *testing.T) {
+	app, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+	insertSnippets(t, app, 5)
+	client := newTestClient(t, ts)
+
+	first := client.listPage(2, "")
+	assert.Equal(t, fmt.Sprint(snippetIDs(first)), "[5 4]")
+
+	insertSnippets(t, app, 3)
+	err := app.snippets.Delete(2)
+	assert.NilError(t, err)
+
+	second := client.listPage(2, *first.Metadata.NextCursor)
+	assert.Equal(t, fmt.Sprint(snippetIDs(second)), "[3 1]")
+	if second.Metadata.NextCursor != nil {
+		t.Errorf("got next cursor %q on the last page", *second.Metadata.NextCursor)
+	}
+
+	// Starting again shows the new snippets first.
+	assert.Equal(t, fmt.Sprint(client.listAll(2)), "[[8 7] [6 5] [4 3] [1]]")
+}
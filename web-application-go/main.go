@@ -1,45 +1,217 @@
 package main
+
 import (
-"log"
-"net/http"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"web-application.antoine.example/internal/health"
+	"web-application.antoine.example/internal/highlight"
+	"web-application.antoine.example/internal/markdown"
+	"web-application.antoine.example/internal/models"
+	"web-application.antoine.example/internal/search"
+	"web-application.antoine.example/internal/sessions"
+	"web-application.antoine.example/ui"
 )
-func home(w http.ResponseWriter, r *http.Request) {
-	// Check if the current request URL path exactly matches "/". If it doesn't, use
-	// the http.NotFound() function to send a 404 response to the client.
-	// Importantly, we then return from the handler. If we don't return the handler
-	// would keep executing and also write the "Hello from SnippetBox" message.
-	if r.URL.Path != "/" {
-	http.NotFound(w, r)
-	return
-	}
-	w.Write([]byte("Hello from Snippetbox"))
-}
-// Add a snippetView handler function.
-func snippetView(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("Display a specific snippet..."))
+
+// application holds the dependencies shared by the handlers. Making the
+// handlers methods on this struct means they get at the storage layer (and
+// anything we add later) without relying on package-level globals.
+type application struct {
+	logger         *slog.Logger
+	snippets       models.SnippetModel
+	users          models.UserModel
+	templateCache  map[string]*template.Template
+	sessionManager *sessions.Manager
+	workers        *backgroundWorkers
+	searchIndex    *search.Index
+	highlights     *highlight.Cache
+	markdown       *highlight.Cache
+	limiters       *rateLimiters
+	snippetCache   *cachedSnippetModel
+	metrics        *appMetrics
+
+	// embedAncestors is the frame-ancestors source list for the embed view,
+	// and publicURL the base of the embed code (empty if not configured).
+	embedAncestors string
+	publicURL      string
+
+	// liveness and readiness back /healthz and /readyz. draining is set
+	// as soon as graceful shutdown begins, which fails readiness; the admin
+	// listener serves /readyz until the public one has drained.
+	liveness  *health.Checker
+	readiness *health.Checker
+	draining  atomic.Bool
 }
 
-func snippetCreate(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		// Use the Header().Set() method to add an 'Allow: POST' header to the
-		// response header map. The first parameter is the header name, and
-		// the second parameter is the header value.
-		w.Header().Set("Allow", "POST")
-		w.WriteHeader(405)
-		w.Write([]byte("Method Not Allowed"))
+// renderCacheSize is the number of snippets whose highlighted HTML (and,
+// separately, rendered Markdown) is kept in memory.
+const renderCacheSize = 1000
+
+func main() {
+	// The generate-cert subcommand creates a self-signed certificate for
+	// local HTTPS, then exits without starting the server.
+	if len(os.Args) > 1 && os.Args[1] == "generate-cert" {
+		err := generateCert(os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, sources, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
 		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
 	}
-	w.Write([]byte("Create a new snippet..."))
+
+	if cfg.printConfig {
+		cfg.write(os.Stdout, sources)
+		return
+	}
+
+	// A structured logger which writes to the standard out stream. Every
+	// component that needs to log gets it through the application struct.
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: cfg.logLevel,
+	}))
+
+	// The exit status tells a supervisor whether the server stopped cleanly:
+	// 0 after a complete drain, 1 if startup failed or the drain was cut
+	// short.
+	err = run(cfg, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	logger.Info("server stopped cleanly")
 }
 
-func main() {
-	// Register the two new handler functions and corresponding URL patterns with
-	// the servemux, in exactly the same way that we did before.
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", home)
-	mux.HandleFunc("/snippet/view", snippetView)
-	mux.HandleFunc("/snippet/create", snippetCreate)
-	log.Println("Starting server on :4000")
-	err := http.ListenAndServe(":4000", mux)
-	log.Fatal(err)
-}
\ No newline at end of file
+// run opens the storage, builds the application and serves it until a
+// shutdown signal arrives. Returning (rather than exiting) from here lets the
+// deferred Close calls flush the storage files.
+func run(cfg config, logger *slog.Logger) error {
+	snippets, err := models.OpenFileSnippetModel(filepath.Join(cfg.dataDir, "snippets.log"))
+	if err != nil {
+		return err
+	}
+	defer snippets.Close()
+
+	users, err := models.OpenFileUserModel(filepath.Join(cfg.dataDir, "users.log"))
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	// Wrap the snippet storage: first in a cache for lookups (unless it is
+	// turned off), then so that the search index follows every change.
+	var snippetStore models.SnippetModel = snippets
+	var snippetCache *cachedSnippetModel
+	if cfg.snippetCache.ttl > 0 {
+		snippetCache = newCachedSnippetModel(snippets, cfg.snippetCache.ttl, cfg.snippetCache.sizeMB<<20)
+		snippetStore = snippetCache
+	}
+
+	searchIndex := search.NewIndex()
+	indexedSnippets, err := newIndexedSnippetModel(snippetStore, searchIndex)
+	if err != nil {
+		return err
+	}
+
+	var staticFS fs.FS = os.DirFS("./ui/static")
+	if !cfg.dev {
+		staticFS, err = fs.Sub(ui.Files, "static")
+		if err != nil {
+			return err
+		}
+	}
+	static := newStaticFiles(staticFS, cfg.dev)
+
+	// Parse every template once, up front. A broken template stops the server
+	// from starting rather than failing on the first request that uses it.
+	templateCache, err := newTemplateCache(static)
+	if err != nil {
+		return err
+	}
+
+	var sessionStore sessions.Store = sessions.NewMemoryStore()
+	if cfg.session.dir != "" {
+		sessionStore, err = sessions.NewFileStore(cfg.session.dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	sessionManager := sessions.New(sessionStore)
+	sessionManager.Lifetime = cfg.session.lifetime
+	sessionManager.IdleTimeout = cfg.session.idleTimeout
+	// Over HTTPS, the session cookie must never be sent in plain text.
+	sessionManager.Cookie.Secure = cfg.useTLS()
+
+	workers := newBackgroundWorkers(logger)
+
+	// Expired sessions are only removed from the store when they are looked
+	// up again, so sweep the rest out regularly.
+	if cleaner, ok := sessionStore.(interface{ Cleanup() (int, error) }); ok {
+		workers.every("session cleanup", 5*time.Minute, func() {
+			removed, err := cleaner.Cleanup()
+			if err != nil {
+				logger.Error("session cleanup failed", "error", err.Error())
+				return
+			}
+			logger.Debug("session cleanup", "removed", removed)
+		})
+	}
+
+	app := &application{
+		logger:         logger,
+		snippets:       indexedSnippets,
+		users:          users,
+		templateCache:  templateCache,
+		sessionManager: sessionManager,
+		workers:        workers,
+		searchIndex:    searchIndex,
+		highlights:     highlight.NewCache(renderCacheSize, highlight.Highlight),
+		markdown:       highlight.NewCache(renderCacheSize, markdown.Render),
+		limiters:       newRateLimiters(cfg),
+		snippetCache:   snippetCache,
+		metrics:        newAppMetrics(snippetCache),
+		embedAncestors: cfg.embedAncestors,
+		publicURL:      cfg.publicURL,
+		liveness:       health.New(healthCheckTimeout, logger),
+	}
+	app.readiness = app.newReadinessChecker(snippets, users)
+	sessionManager.ErrorFunc = app.serverError
+
+	workers.every("rate limiter cleanup", time.Minute, func() {
+		removed := app.limiters.cleanup()
+		if removed > 0 {
+			logger.Debug("rate limiter cleanup", "removed", removed)
+		}
+	})
+
+	workers.start("snippet reaper", func(ctx context.Context) {
+		app.runSnippetReaper(ctx, cfg.reapInterval)
+	})
+
+	err = app.serve(cfg, app.routes(static))
+
+	if snippetCache != nil {
+		stats := snippetCache.stats()
+		logger.Info("snippet cache", "hits", stats.Hits, "misses", stats.Misses,
+			"evictions", stats.Evictions, "entries", stats.Entries)
+	}
+
+	return err
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"web-application.antoine.example/internal/metrics"
+)
+
+// appMetrics holds the metrics the server exposes on /metrics. Names follow
+// the Prometheus conventions: a snippetbox_ prefix for the application's own
+// metrics, go_ for the runtime's, units in the name and _total on counters.
+type appMetrics struct {
+	registry *metrics.Registry
+
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	inFlight        *metrics.Gauge
+
+	snippetsCreated *metrics.Counter
+	snippetViews    *metrics.Counter
+}
+
+// newAppMetrics registers every metric. cache may be nil when the snippet
+// cache is disabled, in which case its metrics stay at zero.
+func newAppMetrics(cache *cachedSnippetModel) *appMetrics {
+	reg := metrics.NewRegistry()
+
+	m := &appMetrics{
+		registry: reg,
+		requests: reg.NewCounterVec("snippetbox_http_requests_total",
+			"HTTP requests handled, by route pattern and status code.", "route", "status"),
+		requestDuration: reg.NewHistogramVec("snippetbox_http_request_duration_seconds",
+			"Time taken to handle HTTP requests, by route pattern and status code.", metrics.DefBuckets, "route", "status"),
+		inFlight: reg.NewGauge("snippetbox_http_requests_in_flight",
+			"HTTP requests currently being handled."),
+		snippetsCreated: reg.NewCounter("snippetbox_snippets_created_total",
+			"Snippets created through the web site or the API."),
+		snippetViews: reg.NewCounter("snippetbox_snippet_views_total",
+			"Snippets viewed through the web site or the API."),
+	}
+
+	reg.NewCounterFunc("snippetbox_snippet_cache_hits_total", "Snippet lookups answered from the cache.",
+		func() float64 { return float64(cache.stats().Hits) })
+	reg.NewCounterFunc("snippetbox_snippet_cache_misses_total", "Snippet lookups which had to go to storage.",
+		func() float64 { return float64(cache.stats().Misses) })
+	reg.NewCounterFunc("snippetbox_snippet_cache_evictions_total", "Snippets evicted from the cache to make room.",
+		func() float64 { return float64(cache.stats().Evictions) })
+	reg.NewGaugeFunc("snippetbox_snippet_cache_entries", "Snippets currently in the cache.",
+		func() float64 { return float64(cache.stats().Entries) })
+	reg.NewGaugeFunc("snippetbox_snippet_cache_hit_ratio", "Fraction of snippet lookups answered from the cache since startup.",
+		func() float64 {
+			stats := cache.stats()
+			if stats.Hits+stats.Misses == 0 {
+				return 0
+			}
+			return float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+		})
+
+	registerRuntimeMetrics(reg)
+
+	return m
+}
+
+// registerRuntimeMetrics adds the goroutine count and memory and garbage
+// collector statistics. runtime.ReadMemStats briefly stops the world, so it
+// is called once per scrape rather than once per metric.
+func registerRuntimeMetrics(reg *metrics.Registry) {
+	var (
+		mu    sync.Mutex
+		stats runtime.MemStats
+	)
+	reg.OnScrape(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		runtime.ReadMemStats(&stats)
+	})
+
+	memStat := func(fn func(*runtime.MemStats) float64) func() float64 {
+		return func() float64 {
+			mu.Lock()
+			defer mu.Unlock()
+			return fn(&stats)
+		}
+	}
+
+	reg.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+	reg.NewCounterFunc("go_gc_cycles_total", "Completed garbage collection cycles.",
+		memStat(func(s *runtime.MemStats) float64 { return float64(s.NumGC) }))
+	reg.NewCounterFunc("go_gc_pause_seconds_total", "Total time the world has been stopped for garbage collection.",
+		memStat(func(s *runtime.MemStats) float64 { return time.Duration(s.PauseTotalNs).Seconds() }))
+	reg.NewGaugeFunc("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects.",
+		memStat(func(s *runtime.MemStats) float64 { return float64(s.HeapAlloc) }))
+	reg.NewGaugeFunc("go_memstats_heap_objects", "Number of allocated heap objects.",
+		memStat(func(s *runtime.MemStats) float64 { return float64(s.HeapObjects) }))
+	reg.NewGaugeFunc("go_memstats_next_gc_bytes", "Heap size at which the next garbage collection will run.",
+		memStat(func(s *runtime.MemStats) float64 { return float64(s.NextGC) }))
+	reg.NewGaugeFunc("go_memstats_sys_bytes", "Bytes of memory obtained from the operating system.",
+		memStat(func(s *runtime.MemStats) float64 { return float64(s.Sys) }))
+}
+
+// instrument counts and times every request, labelled with the servemux
+// pattern which matched it rather than the path, so that the number of
+// series stays bounded no matter which URLs clients ask for. Requests which
+// matched no route are labelled "unmatched".
+//
+// The servemux sets r.Pattern on the request it is given, so the pattern can
+// be read here once the handler has returned, as long as no middleware in
+// between replaces the request.
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		app.metrics.inFlight.Inc()
+		defer app.metrics.inFlight.Dec()
+
+		next.ServeHTTP(rw, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rw.status)
+
+		app.metrics.requests.With(route, status).Inc()
+		app.metrics.requestDuration.With(route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// adminRoutes returns the handler for the admin listener, which is kept
+// separate from the public one so that metrics are never exposed to the
+// internet by accident. It is also the only place /readyz is served, since
+// each call writes to the data directories. The admin server is the last to
+// shut down, so /readyz keeps reporting the drain while it lasts.
+func (app *application) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", app.metrics.registry.Handler())
+	mux.Handle("GET /healthz", app.liveness.Handler())
+	mux.Handle("GET /readyz", app.readiness.Handler())
+	return mux
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// middleware is the signature shared by every middleware in the application:
+// it takes the next handler in the chain and returns a handler wrapping it.
+type middleware func(http.Handler) http.Handler
+
+// chain is an ordered list of middleware which can be applied to a handler in
+// one go. The first middleware in the list is the outermost one, which means
+// it sees the request first and the response last.
+type chain []middleware
+
+func newChain(middlewares ...middleware) chain {
+	return chain(middlewares)
+}
+
+// append returns a new chain with more middleware added inside the existing
+// ones. The original chain is left untouched, so a base chain can be shared
+// by several groups of routes.
+func (c chain) append(middlewares ...middleware) chain {
+	extended := make(chain, 0, len(c)+len(middlewares))
+	extended = append(extended, c...)
+	return append(extended, middlewares...)
+}
+
+// then wraps h in every middleware in the chain.
+func (c chain) then(h http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return h
+}
+
+// thenFunc is a shortcut for then(http.HandlerFunc(fn)).
+func (c chain) thenFunc(fn http.HandlerFunc) http.Handler {
+	return c.then(fn)
+}
+
+// contentSecurityPolicy is the policy for every page, apart from the
+// frame-ancestors directive, which allowFraming changes for embeddable pages.
+const contentSecurityPolicy = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+
+// secureHeaders sets the headers which tell browsers to lock the page down:
+// only load resources from our own origin, don't leak full URLs to other
+// sites, don't sniff content types and don't allow the page to be framed.
+func secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy+"; frame-ancestors 'none'")
+		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "deny")
+		w.Header().Set("X-XSS-Protection", "0")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowFraming lets the pages it wraps be shown in a frame on the given
+// ancestors, a space-separated list of origins (or "*" for any site). It
+// must run after secureHeaders, whose headers it overrides.
+func allowFraming(ancestors string) middleware {
+	policy := contentSecurityPolicy + "; frame-ancestors " + ancestors
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", policy)
+			// X-Frame-Options can't list origins, and browsers which
+			// understand frame-ancestors ignore it anyway.
+			w.Header().Del("X-Frame-Options")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// responseRecorder wraps a http.ResponseWriter to remember the status code
+// and the number of bytes written, so they can be logged once the handler
+// returns.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, for
+// example to flush it.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// logRequest writes one structured log line per request once the response
+// has been sent.
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		app.logger.Info("request",
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"proto", r.Proto,
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// recoverPanic turns a panic in a later handler into a logged 500 response.
+// Setting "Connection: close" makes Go's HTTP server close the connection
+// once the response is sent, since we can't know what state it is in.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Create a deferred function (which will always be run in the event
+		// of a panic as Go unwinds the stack).
+		defer func() {
+			pv := recover()
+			if pv == nil {
+				return
+			}
+			// http.ErrAbortHandler is the server's own way of aborting a
+			// response, so let it through.
+			if pv == http.ErrAbortHandler {
+				panic(pv)
+			}
+
+			w.Header().Set("Connection", "close")
+			app.serverError(w, r, fmt.Errorf("%v", pv))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the session for an authenticated user ID and, if that
+// user still exists, marks the request as authenticated in its context. It
+// must run after the session has been loaded.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		exists, err := app.users.Exists(id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if exists {
+			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAuthentication sends users who aren't logged in to the login page.
+// The URL they asked for is kept in the session so that userLoginPost can
+// send them back to it afterwards.
+func (app *application) requireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			// Only remember GET requests: redirecting to a POST URL after
+			// login would turn it into a GET with no form data.
+			if r.Method == http.MethodGet {
+				app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", r.URL.RequestURI())
+			}
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		// Pages that require authentication must not be stored in a browser
+		// (or other intermediary) cache.
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
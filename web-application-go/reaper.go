@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runSnippetReaper physically deletes expired snippets every interval until
+// ctx is cancelled. Expired snippets are already invisible to every handler
+// (the models filter them out), so the reaper only frees the memory they
+// take up, along with their search index and cache entries. It doesn't
+// shrink the storage file: the log is append-only, so each pass which
+// removes something adds one more entry to it. It runs as a background
+// worker, so shutdown stops it by cancelling ctx.
+func (app *application) runSnippetReaper(ctx context.Context, interval time.Duration) {
+	// Start with a pass straight away, to catch anything which expired while
+	// the server was down.
+	app.reapExpiredSnippets()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			app.reapExpiredSnippets()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reapExpiredSnippets runs a single reaper pass and logs what it removed.
+func (app *application) reapExpiredSnippets() {
+	ids, err := app.snippets.DeleteExpired()
+	if err != nil {
+		app.logger.Error("snippet reaper failed", "error", err.Error())
+		return
+	}
+
+	if len(ids) > 0 {
+		app.logger.Info("snippet reaper removed expired snippets", "count", len(ids), "ids", ids)
+	} else {
+		app.logger.Debug("snippet reaper found nothing to remove")
+	}
+}
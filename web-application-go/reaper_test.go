@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"web-application.antoine.example/internal/assert"
+	"web-application.antoine.example/internal/models"
+)
+
+func TestSnippetReaper(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		app, _ := newTestApplication(t)
+
+		insert := func(title string, expires int) int {
+			t.Helper()
+			id, err := app.snippets.Insert(models.NewSnippet{Title: title, Content: "content", Expires: expires})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return id
+		}
+
+		// One snippet expired while the server was down, one is still live
+		// and one expires after the first pass.
+		insert("Expired", -1)
+		live := insert("Live", 7)
+		insert("Expiring", 1)
+		assert.Equal(t, app.searchIndex.Len(), 3)
+
+		app.workers.start("snippet reaper", func(ctx context.Context) {
+			app.runSnippetReaper(ctx, time.Hour)
+		})
+
+		// The first pass runs straight away.
+		synctest.Wait()
+		assert.Equal(t, app.searchIndex.Len(), 2)
+
+		time.Sleep(24 * time.Hour)
+		synctest.Wait()
+		assert.Equal(t, app.searchIndex.Len(), 1)
+
+		// Nothing is left for another pass to remove.
+		ids, err := app.snippets.DeleteExpired()
+		assert.NilError(t, err)
+		assert.Equal(t, len(ids), 0)
+
+		_, err = app.snippets.Get(live)
+		assert.NilError(t, err)
+
+		// Shutdown stops the reaper, which doesn't count as it failing.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NilError(t, app.workers.stop(ctx))
+		assert.NilError(t, app.workers.check(ctx))
+	})
+}
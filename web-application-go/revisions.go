@@ -0,0 +1,607 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"web-application.antoine.example/internal/diff"
+	"web-application.antoine.example/internal/models"
+)
+
+// Editing a snippet adds a revision rather than overwriting it, so every
+// version can still be looked at, compared with any other and restored.
+// Restoring makes a new revision with the old content, which keeps the
+// history append-only. Only the author of a snippet can edit or restore it.
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// revisionItem is a revision with the name of the user who made it, for the
+// history page.
+type revisionItem struct {
+	models.Revision
+	Author string
+}
+
+// revisionDiff compares two revisions of a snippet.
+type revisionDiff struct {
+	From  models.Revision
+	To    models.Revision
+	Hunks []diff.Hunk
+}
+
+// Unified returns the comparison as a unified diff of the content.
+func (d revisionDiff) Unified() string {
+	return diff.Unified(fmt.Sprintf("revision %d", d.From.Number), fmt.Sprintf("revision %d", d.To.Number),
+		d.From.Content, d.To.Content)
+}
+
+// canEdit reports whether the logged-in user, if any, may edit a snippet.
+// Snippets created before user accounts existed have no author, so nobody
+// can edit them.
+func (app *application) canEdit(r *http.Request, snippet models.Snippet) bool {
+	return snippet.AuthorID != 0 && snippet.AuthorID == app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// findRevision returns revision n from revisions, or ErrNoRecord.
+func findRevision(revisions []models.Revision, n int) (models.Revision, error) {
+	// Revisions are numbered from 1 with no gaps, but don't rely on it.
+	i := slices.IndexFunc(revisions, func(rev models.Revision) bool { return rev.Number == n })
+	if i < 0 {
+		return models.Revision{}, models.ErrNoRecord
+	}
+	return revisions[i], nil
+}
+
+// revisionParam reads a revision number from a query parameter, with
+// fallback as its value if it is missing.
+func revisionParam(r *http.Request, name string, fallback int) (int, bool) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// compareRevisions diffs two of a snippet's revisions. It returns
+// ErrNoRecord if either doesn't exist.
+func compareRevisions(revisions []models.Revision, from, to int) (revisionDiff, error) {
+	fromRev, err := findRevision(revisions, from)
+	if err != nil {
+		return revisionDiff{}, err
+	}
+	toRev, err := findRevision(revisions, to)
+	if err != nil {
+		return revisionDiff{}, err
+	}
+
+	return revisionDiff{
+		From:  fromRev,
+		To:    toRev,
+		Hunks: diff.Hunks(fromRev.Content, toRev.Content, diffContext),
+	}, nil
+}
+
+// restoreRevision makes a new revision of a snippet with the content of
+// revision n.
+func (app *application) restoreRevision(id, n, authorID int) (models.Revision, error) {
+	revisions, err := app.snippets.Revisions(id)
+	if err != nil {
+		return models.Revision{}, err
+	}
+	old, err := findRevision(revisions, n)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	return app.snippets.Update(id, models.SnippetUpdate{
+		Title:        old.Title,
+		Content:      old.Content,
+		Tags:         old.Tags,
+		Language:     old.Language,
+		AuthorID:     authorID,
+		RestoredFrom: old.Number,
+	})
+}
+
+// snippetForEdit looks up the snippet to be edited by the request, sending a
+// 404 if it doesn't exist or a 403 if the user isn't its author. It returns
+// false if a response has been sent.
+func (app *application) snippetForEdit(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.notFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	if !app.canEdit(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetForm{
+		Title:    snippet.Title,
+		Content:  snippet.Content,
+		Tags:     snippet.Tags,
+		Language: snippet.Language,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := snippetForm{
+		Title:    r.PostForm.Get("title"),
+		Content:  formContent(r),
+		Tags:     parseTags(r.PostForm.Get("tags")),
+		Language: r.PostForm.Get("language"),
+	}
+
+	form.validateContent()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	authorID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	rev, err := app.snippets.Update(snippet.ID, form.snippetUpdate(authorID))
+	switch {
+	case errors.Is(err, models.ErrNoChange):
+		app.sessionManager.Put(r.Context(), "flash", "No changes to save.")
+	case errors.Is(err, models.ErrNoRecord):
+		// The snippet expired or was deleted while it was being edited.
+		app.notFound(w, r)
+		return
+	case err != nil:
+		app.serverError(w, r, err)
+		return
+	default:
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet updated to revision %d.", rev.Number))
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetHistory lists a snippet's revisions, newest first.
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	items := make([]revisionItem, len(revisions))
+	for i, rev := range revisions {
+		author, err := app.authorName(rev.AuthorID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		items[len(items)-1-i] = revisionItem{Revision: rev, Author: author}
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.History = items
+	data.CanEdit = app.canEdit(r, snippet)
+
+	app.render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+// snippetDiff shows the changes between two revisions, given as the from and
+// to query parameters. By default it shows the latest change.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	to, ok := revisionParam(r, "to", snippet.Revision)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	from, ok := revisionParam(r, "from", max(to-1, 1))
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	d, err := compareRevisions(revisions, from, to)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Diff = d
+
+	app.render(w, r, http.StatusOK, "diff.tmpl", data)
+}
+
+func (app *application) snippetRestorePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	n, err := strconv.Atoi(r.PostForm.Get("revision"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	authorID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	rev, err := app.restoreRevision(snippet.ID, n, authorID)
+	switch {
+	case errors.Is(err, models.ErrNoChange):
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Revision %d is the same as the current version.", n))
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w, r)
+		return
+	case err != nil:
+		app.serverError(w, r, err)
+		return
+	default:
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Revision %d restored as revision %d.", n, rev.Number))
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// revisionJSON is the API representation of a revision.
+type revisionJSON struct {
+	Number       int       `json:"number"`
+	Title        string    `json:"title"`
+	Content      string    `json:"content"`
+	Tags         []string  `json:"tags"`
+	Language     string    `json:"language,omitempty"`
+	Created      time.Time `json:"created"`
+	AuthorID     int       `json:"author_id,omitempty"`
+	RestoredFrom int       `json:"restored_from,omitempty"`
+}
+
+func newRevisionJSON(rev models.Revision) revisionJSON {
+	out := revisionJSON{
+		Number:       rev.Number,
+		Title:        rev.Title,
+		Content:      rev.Content,
+		Tags:         rev.Tags,
+		Language:     rev.Language,
+		Created:      rev.Created,
+		AuthorID:     rev.AuthorID,
+		RestoredFrom: rev.RestoredFrom,
+	}
+	if out.Tags == nil {
+		out.Tags = []string{}
+	}
+	return out
+}
+
+// apiSnippetForEdit is snippetForEdit for the API.
+func (app *application) apiSnippetForEdit(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.apiNotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	if !app.canEdit(r, snippet) {
+		app.writeProblem(w, r, problem{
+			Status: http.StatusForbidden,
+			Detail: "Only the author of a snippet can edit it.",
+		})
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// apiSnippetUpdate handles PATCH /api/v1/snippets/{id}. Fields left out of
+// the body keep their current values. An update which changes nothing
+// succeeds without making a revision.
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiSnippetForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title    *string  `json:"title"`
+		Content  *string  `json:"content"`
+		Tags     []string `json:"tags"`
+		Language *string  `json:"language"`
+	}
+
+	err := readJSON(w, r, &input)
+	if err != nil {
+		app.readJSONError(w, r, err)
+		return
+	}
+
+	form := snippetForm{
+		Title:    snippet.Title,
+		Content:  snippet.Content,
+		Tags:     snippet.Tags,
+		Language: snippet.Language,
+	}
+	if input.Title != nil {
+		form.Title = *input.Title
+	}
+	if input.Content != nil {
+		form.Content = *input.Content
+	}
+	if input.Tags != nil {
+		form.Tags = normalizeTags(input.Tags)
+	}
+	if input.Language != nil {
+		form.Language = *input.Language
+	}
+	form.validateContent()
+
+	if !form.Valid() {
+		app.writeProblem(w, r, problem{
+			Status: http.StatusUnprocessableEntity,
+			Detail: "The snippet failed validation.",
+			Errors: form.FieldErrors,
+		})
+		return
+	}
+
+	authorID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	_, err = app.snippets.Update(snippet.ID, form.snippetUpdate(authorID))
+	if err != nil && !errors.Is(err, models.ErrNoChange) {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeUpdatedSnippet(w, r, snippet.ID)
+}
+
+// apiSnippetRevisions handles GET /api/v1/snippets/{id}/revisions. The
+// revisions are listed oldest first.
+func (app *application) apiSnippetRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, ok := app.apiRevisions(w, r)
+	if !ok {
+		return
+	}
+
+	out := make([]revisionJSON, len(revisions))
+	for i, rev := range revisions {
+		out[i] = newRevisionJSON(rev)
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"revisions": out})
+}
+
+// apiSnippetRevision handles GET /api/v1/snippets/{id}/revisions/{rev}.
+func (app *application) apiSnippetRevision(w http.ResponseWriter, r *http.Request) {
+	revisions, ok := app.apiRevisions(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		app.apiNotFound(w, r)
+		return
+	}
+	rev, err := findRevision(revisions, n)
+	if err != nil {
+		app.apiNotFound(w, r)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"revision": newRevisionJSON(rev)})
+}
+
+// apiSnippetDiff handles GET /api/v1/snippets/{id}/diff, with the same from
+// and to parameters as the diff page. The diff is returned in unified
+// format.
+func (app *application) apiSnippetDiff(w http.ResponseWriter, r *http.Request) {
+	revisions, ok := app.apiRevisions(w, r)
+	if !ok {
+		return
+	}
+
+	errs := make(map[string]string)
+	to, ok := revisionParam(r, "to", revisions[len(revisions)-1].Number)
+	if !ok {
+		errs["to"] = "must be a revision number"
+	}
+	from, ok := revisionParam(r, "from", max(to-1, 1))
+	if !ok {
+		errs["from"] = "must be a revision number"
+	}
+	if len(errs) > 0 {
+		app.writeProblem(w, r, problem{
+			Status: http.StatusBadRequest,
+			Detail: "Invalid query parameters.",
+			Errors: errs,
+		})
+		return
+	}
+
+	d, err := compareRevisions(revisions, from, to)
+	if err != nil {
+		app.apiNotFound(w, r)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{
+		"from": d.From.Number,
+		"to":   d.To.Number,
+		"diff": d.Unified(),
+	})
+}
+
+// apiSnippetRestore handles POST /api/v1/snippets/{id}/revisions/{rev}/restore.
+// Restoring a revision which is the same as the current one succeeds without
+// making a new revision.
+func (app *application) apiSnippetRestore(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiSnippetForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		app.apiNotFound(w, r)
+		return
+	}
+
+	authorID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	_, err = app.restoreRevision(snippet.ID, n, authorID)
+	if err != nil && !errors.Is(err, models.ErrNoChange) {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeUpdatedSnippet(w, r, snippet.ID)
+}
+
+// apiRevisions returns the revisions of the snippet in the {id} path value,
+// or sends a 404 and returns false.
+func (app *application) apiRevisions(w http.ResponseWriter, r *http.Request) ([]models.Revision, bool) {
+	id, ok := snippetIDParam(r)
+	if !ok {
+		app.apiNotFound(w, r)
+		return nil, false
+	}
+
+	revisions, err := app.snippets.Revisions(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return nil, false
+	}
+	return revisions, true
+}
+
+// writeUpdatedSnippet sends the current version of a snippet after a change.
+func (app *application) writeUpdatedSnippet(w http.ResponseWriter, r *http.Request, id int) {
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{"snippet": newSnippetJSON(snippet)})
+}
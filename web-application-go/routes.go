@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// routes registers every handler on a new servemux and wraps it in the
+// middleware that applies to all requests. It is called once at startup.
+//
+// The patterns include the HTTP method, so the servemux itself answers
+// requests using the wrong method with a 405 and an Allow header. A GET
+// pattern also matches HEAD requests.
+func (app *application) routes(static http.Handler) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("GET /static/", http.StripPrefix("/static", static))
+
+	// Liveness probe for an orchestrator or load balancer. Like static
+	// files, it has no session and no rate limit, since it is polled
+	// constantly from the same address, and it runs no checks. /readyz
+	// writes to disk on every call, so it is only served on the admin
+	// listener.
+	mux.Handle("GET /healthz", app.liveness.Handler())
+
+	// Middleware for the dynamic pages. Static files don't need a session,
+	// so they are registered outside of it. They aren't rate limited either:
+	// they are cheap to serve and browsers cache them.
+	dynamic := newChain(app.limitRequests, app.sessionManager.LoadAndSave, app.verifyCSRFToken, app.authenticate)
+
+	// Routes that need a logged-in user.
+	protected := dynamic.append(app.requireAuthentication)
+
+	// The {$} wildcard makes "/" match only the root, rather than acting as
+	// a catch-all for every path.
+	mux.Handle("GET /{$}", dynamic.thenFunc(app.home))
+	mux.Handle("GET /snippet/view", dynamic.thenFunc(app.snippetView))
+	mux.Handle("GET /snippet/view/{id}", dynamic.thenFunc(app.snippetView))
+	mux.Handle("GET /snippet/view/{id}/history", dynamic.thenFunc(app.snippetHistory))
+	mux.Handle("GET /snippet/view/{id}/diff", dynamic.thenFunc(app.snippetDiff))
+	mux.Handle("GET /search", dynamic.thenFunc(app.search))
+	mux.Handle("GET /user/signup", dynamic.thenFunc(app.userSignup))
+	mux.Handle("POST /user/signup", dynamic.thenFunc(app.userSignupPost))
+	mux.Handle("GET /user/login", dynamic.thenFunc(app.userLogin))
+	mux.Handle("POST /user/login", dynamic.append(app.limitLogins).thenFunc(app.userLoginPost))
+
+	mux.Handle("GET /snippet/create", protected.thenFunc(app.snippetCreate))
+	mux.Handle("POST /snippet/create", protected.thenFunc(app.snippetCreatePost))
+	mux.Handle("GET /snippet/edit/{id}", protected.thenFunc(app.snippetEdit))
+	mux.Handle("POST /snippet/edit/{id}", protected.thenFunc(app.snippetEditPost))
+	mux.Handle("POST /snippet/restore/{id}", protected.thenFunc(app.snippetRestorePost))
+	mux.Handle("POST /user/logout", protected.thenFunc(app.userLogoutPost))
+
+	// The raw, download and embed views don't use the session, so they are
+	// only rate limited. The embed view is the one page other sites may
+	// show in a frame.
+	public := newChain(app.limitRequests)
+
+	mux.Handle("GET /snippet/raw/{id}", public.thenFunc(app.snippetRaw))
+	mux.Handle("GET /snippet/download/{id}", public.thenFunc(app.snippetDownload))
+	mux.Handle("GET /snippet/embed/{id}", public.append(allowFraming(app.embedAncestors)).thenFunc(app.snippetEmbed))
+
+	// The JSON API shares the session with the HTML site, so a logged-in
+	// browser can use it directly. It doesn't check the form CSRF token:
+	// writes must be sent as application/json, which browsers won't do
+	// cross-origin without a preflight, and preventCrossOrigin still
+	// applies.
+	api := newChain(app.limitRequests, app.sessionManager.LoadAndSave, app.authenticate)
+	apiProtected := api.append(app.apiRequireAuthentication)
+
+	mux.Handle("GET /api/v1/snippets", api.thenFunc(app.apiSnippetList))
+	mux.Handle("POST /api/v1/snippets", apiProtected.thenFunc(app.apiSnippetCreate))
+	mux.Handle("GET /api/v1/snippets/{id}", api.thenFunc(app.apiSnippetGet))
+	mux.Handle("PATCH /api/v1/snippets/{id}", apiProtected.thenFunc(app.apiSnippetUpdate))
+	mux.Handle("DELETE /api/v1/snippets/{id}", apiProtected.thenFunc(app.apiSnippetDelete))
+	mux.Handle("GET /api/v1/snippets/{id}/revisions", api.thenFunc(app.apiSnippetRevisions))
+	mux.Handle("GET /api/v1/snippets/{id}/revisions/{rev}", api.thenFunc(app.apiSnippetRevision))
+	mux.Handle("POST /api/v1/snippets/{id}/revisions/{rev}/restore", apiProtected.thenFunc(app.apiSnippetRestore))
+	mux.Handle("GET /api/v1/snippets/{id}/diff", api.thenFunc(app.apiSnippetDiff))
+	mux.Handle("GET /api/v1/search", api.thenFunc(app.apiSearch))
+
+	// Anything else under /api/ gets a problem+json 404 or 405, rather than
+	// the plain text responses the servemux would send.
+	mux.Handle("/api/", app.apiFallback(mux))
+
+	// The outermost middleware runs first. logRequest and instrument wrap
+	// recoverPanic so that the 500 sent after a panic still shows up in the
+	// request log and the metrics.
+	standard := newChain(app.logRequest, app.instrument, app.recoverPanic, secureHeaders, app.preventCrossOrigin)
+
+	return standard.then(handleOptions(mux))
+}
+
+// probeMethods are the methods checked when working out which ones a path
+// supports for an OPTIONS request.
+var probeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// allowedMethods returns the methods that mux has a route for at the path of
+// r. Catch-all patterns without a method (such as "/api/") don't count.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range probeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		_, pattern := mux.Handler(probe)
+		if pattern != "" && !strings.HasPrefix(pattern, "/") {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// handleOptions answers OPTIONS requests for any path registered on mux with
+// a 204 and an Allow header listing the methods the path supports, so the
+// list can never drift from the routes. Paths with no routes get a 404. All
+// other requests are passed straight to mux.
+func handleOptions(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := allowedMethods(mux, r)
+		if len(allowed) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/assert"
+	"web-application.antoine.example/internal/models"
+)
+
+// routeTests has an entry for every pattern registered in routes, grouped by
+// path: the patterns for the path, a URL which matches them and the status a
+// HEAD request gets (0 if the path has no GET route).
+var routeTests = []struct {
+	patterns   []string
+	url        string
+	headStatus int
+}{
+	{[]string{"GET /static/"}, "/static/css/main.css", http.StatusOK},
+	{[]string{"GET /healthz"}, "/healthz", http.StatusOK},
+	{[]string{"GET /{$}"}, "/", http.StatusOK},
+	{[]string{"GET /snippet/view"}, "/snippet/view?id=1", http.StatusOK},
+	{[]string{"GET /snippet/view/{id}"}, "/snippet/view/1", http.StatusOK},
+	{[]string{"GET /snippet/view/{id}/history"}, "/snippet/view/1/history", http.StatusOK},
+	{[]string{"GET /snippet/view/{id}/diff"}, "/snippet/view/1/diff", http.StatusOK},
+	{[]string{"GET /search"}, "/search?q=go", http.StatusOK},
+	{[]string{"GET /user/signup", "POST /user/signup"}, "/user/signup", http.StatusOK},
+	{[]string{"GET /user/login", "POST /user/login"}, "/user/login", http.StatusOK},
+	{[]string{"GET /snippet/create", "POST /snippet/create"}, "/snippet/create", http.StatusSeeOther},
+	{[]string{"GET /snippet/edit/{id}", "POST /snippet/edit/{id}"}, "/snippet/edit/1", http.StatusSeeOther},
+	{[]string{"POST /snippet/restore/{id}"}, "/snippet/restore/1", 0},
+	{[]string{"POST /user/logout"}, "/user/logout", 0},
+	{[]string{"GET /snippet/raw/{id}"}, "/snippet/raw/1", http.StatusOK},
+	{[]string{"GET /snippet/download/{id}"}, "/snippet/download/1", http.StatusOK},
+	{[]string{"GET /snippet/embed/{id}"}, "/snippet/embed/1", http.StatusOK},
+	{[]string{"GET /api/v1/snippets", "POST /api/v1/snippets"}, "/api/v1/snippets", http.StatusOK},
+	{[]string{"GET /api/v1/snippets/{id}", "PATCH /api/v1/snippets/{id}", "DELETE /api/v1/snippets/{id}"}, "/api/v1/snippets/1", http.StatusOK},
+	{[]string{"GET /api/v1/snippets/{id}/revisions"}, "/api/v1/snippets/1/revisions", http.StatusOK},
+	{[]string{"GET /api/v1/snippets/{id}/revisions/{rev}"}, "/api/v1/snippets/1/revisions/1", http.StatusOK},
+	{[]string{"POST /api/v1/snippets/{id}/revisions/{rev}/restore"}, "/api/v1/snippets/1/revisions/1/restore", 0},
+	{[]string{"GET /api/v1/snippets/{id}/diff"}, "/api/v1/snippets/1/diff", http.StatusOK},
+	{[]string{"GET /api/v1/search"}, "/api/v1/search?q=go", http.StatusOK},
+}
+
+// TestRouteTableComplete checks that routeTests covers every pattern in
+// routes.go, so a new route can't go untested.
+func TestRouteTableComplete(t *testing.T) {
+	src, err := os.ReadFile("routes.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var registered []string
+	for _, m := range regexp.MustCompile(`mux\.Handle\("([^"]+)"`).FindAllStringSubmatch(string(src), -1) {
+		// The /api/ catch-all is tested on its own.
+		if m[1] != "/api/" {
+			registered = append(registered, m[1])
+		}
+	}
+
+	var tested []string
+	for _, tt := range routeTests {
+		tested = append(tested, tt.patterns...)
+	}
+
+	slices.Sort(registered)
+	slices.Sort(tested)
+	assert.Equal(t, strings.Join(tested, "\n"), strings.Join(registered, "\n"))
+}
+
+// allowedFor returns the methods a path with the given patterns supports:
+// those of its patterns, plus HEAD wherever there is a GET.
+func allowedFor(patterns []string) []string {
+	var allowed []string
+	for _, p := range patterns {
+		method, _, _ := strings.Cut(p, " ")
+		allowed = append(allowed, method)
+		if method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	slices.Sort(allowed)
+	return allowed
+}
+
+// splitAllow returns the methods in an Allow header, sorted.
+func splitAllow(header string) []string {
+	methods := strings.Split(header, ", ")
+	slices.Sort(methods)
+	return methods
+}
+
+func TestRouteMethods(t *testing.T) {
+	app, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	_, err := app.snippets.Insert(models.NewSnippet{Title: "Go", Content: "package main", Expires: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(t *testing.T, method, url string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, ts.URL+url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	for _, tt := range routeTests {
+		allowed := allowedFor(tt.patterns)
+
+		t.Run(tt.url, func(t *testing.T) {
+			for _, method := range probeMethods {
+				if slices.Contains(allowed, method) {
+					continue
+				}
+
+				t.Run(method, func(t *testing.T) {
+					res := do(t, method, tt.url)
+					assert.Equal(t, res.StatusCode, http.StatusMethodNotAllowed)
+					assert.Equal(t, strings.Join(splitAllow(res.Header.Get("Allow")), ", "), strings.Join(allowed, ", "))
+
+					if strings.HasPrefix(tt.url, "/api/") {
+						assert.Equal(t, res.Header.Get("Content-Type"), "application/problem+json")
+					}
+				})
+			}
+
+			if tt.headStatus != 0 {
+				t.Run(http.MethodHead, func(t *testing.T) {
+					res := do(t, http.MethodHead, tt.url)
+					assert.Equal(t, res.StatusCode, tt.headStatus)
+				})
+			}
+
+			t.Run(http.MethodOptions, func(t *testing.T) {
+				res := do(t, http.MethodOptions, tt.url)
+				assert.Equal(t, res.StatusCode, http.StatusNoContent)
+				want := strings.Join(allowedFor(append(tt.patterns, "OPTIONS")), ", ")
+				assert.Equal(t, strings.Join(splitAllow(res.Header.Get("Allow")), ", "), want)
+			})
+		})
+	}
+}
+
+func TestAPIFallback(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+	}{
+		{"Unknown path", http.MethodGet, "/api/v1/nothing", http.StatusNotFound},
+		{"Unknown version", http.MethodGet, "/api/v2/snippets", http.StatusNotFound},
+		{"Wrong method", http.MethodPut, "/api/v1/snippets", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			assert.Equal(t, res.StatusCode, tt.wantStatus)
+			assert.Equal(t, res.Header.Get("Content-Type"), "application/problem+json")
+		})
+	}
+}
+
+func TestOptionsUnknownPath(t *testing.T) {
+	_, routes := newTestApplication(t)
+	ts := newTestServer(t, routes)
+
+	req, err := http.NewRequest(http.MethodOptions, ts.URL+"/no/such/page", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, http.StatusNotFound)
+	assert.Equal(t, res.Header.Get("Allow"), "")
+}
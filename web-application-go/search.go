@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"web-application.antoine.example/internal/models"
+	"web-application.antoine.example/internal/search"
+	"web-application.antoine.example/internal/validator"
+)
+
+// indexedSnippetModel wraps a SnippetModel and keeps a search index in step
+// with it: snippets are indexed when created, re-indexed when updated and
+// dropped when deleted or reaped. Reads go straight to the wrapped model.
+type indexedSnippetModel struct {
+	models.SnippetModel
+	index *search.Index
+}
+
+// newIndexedSnippetModel indexes every snippet already in m and returns the
+// wrapped model.
+func newIndexedSnippetModel(m models.SnippetModel, index *search.Index) (*indexedSnippetModel, error) {
+	snippets, _, err := m.List(models.ListQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range snippets {
+		index.Add(s.ID, s.Title, s.Content)
+	}
+
+	return &indexedSnippetModel{SnippetModel: m, index: index}, nil
+}
+
+func (m *indexedSnippetModel) Insert(n models.NewSnippet) (int, error) {
+	id, err := m.SnippetModel.Insert(n)
+	if err != nil {
+		return 0, err
+	}
+
+	m.index.Add(id, n.Title, n.Content)
+	return id, nil
+}
+
+func (m *indexedSnippetModel) Update(id int, u models.SnippetUpdate) (models.Revision, error) {
+	rev, err := m.SnippetModel.Update(id, u)
+	if err != nil {
+		return rev, err
+	}
+
+	m.index.Add(id, rev.Title, rev.Content)
+	return rev, nil
+}
+
+func (m *indexedSnippetModel) Delete(id int) error {
+	err := m.SnippetModel.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	m.index.Remove(id)
+	return nil
+}
+
+func (m *indexedSnippetModel) DeleteExpired() ([]int, error) {
+	ids, err := m.SnippetModel.DeleteExpired()
+	for _, id := range ids {
+		m.index.Remove(id)
+	}
+	return ids, err
+}
+
+// searchResult is a snippet matching a search, with its title and an excerpt
+// of its content highlighted.
+type searchResult struct {
+	Snippet models.Snippet
+	Score   float64
+	Title   []search.Fragment
+	Excerpt []search.Fragment
+}
+
+// searchPage holds one page of search results, for both the HTML page and
+// the API.
+type searchPage struct {
+	Query    string
+	Results  []searchResult
+	Total    int
+	Page     int
+	PageSize int
+}
+
+func (p searchPage) LastPage() int {
+	return max(1, (p.Total+p.PageSize-1)/p.PageSize)
+}
+
+func (p searchPage) PrevPage() int {
+	if p.Page <= 1 {
+		return 0
+	}
+	return p.Page - 1
+}
+
+func (p searchPage) NextPage() int {
+	if p.Page >= p.LastPage() {
+		return 0
+	}
+	return p.Page + 1
+}
+
+// excerptChars is the approximate length of the content excerpt shown for
+// each result.
+const excerptChars = 200
+
+// searchSnippets runs a query against the index and builds the requested
+// page of results. Hits for snippets which have expired since they were
+// indexed (but haven't been reaped yet) are skipped.
+func (app *application) searchSnippets(query string, page, pageSize int) (searchPage, error) {
+	result := searchPage{Query: query, Page: page, PageSize: pageSize}
+
+	var matches []searchResult
+	for _, hit := range app.searchIndex.Search(query) {
+		snippet, err := app.snippets.Get(hit.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				continue
+			}
+			return result, err
+		}
+		matches = append(matches, searchResult{Snippet: snippet, Score: hit.Score})
+	}
+
+	result.Total = len(matches)
+
+	// Pages past the last one are empty. The page number is compared with
+	// the number of pages before it is turned into an offset, since a
+	// large ?page= would overflow the multiplication.
+	start := len(matches)
+	if page-1 < (len(matches)+pageSize-1)/pageSize {
+		start = (page - 1) * pageSize
+	}
+	end := min(start+pageSize, len(matches))
+	result.Results = matches[start:end]
+
+	// Only highlight the results that are actually shown.
+	terms := search.Terms(query)
+	for i := range result.Results {
+		r := &result.Results[i]
+		r.Title = search.Highlight(r.Snippet.Title, terms)
+		r.Excerpt = search.Excerpt(r.Snippet.Content, terms, excerptChars)
+	}
+
+	return result, nil
+}
+
+// pageParam reads a positive page number from the query string, defaulting
+// to 1. ok is false if the parameter is present but invalid.
+func pageParam(r *http.Request) (page int, ok bool) {
+	v := r.URL.Query().Get("page")
+	if v == "" {
+		return 1, true
+	}
+	page, err := strconv.Atoi(v)
+	if err != nil || page < 1 {
+		return 0, false
+	}
+	return page, true
+}
+
+// searchPageSize is the number of results per page on the HTML search page.
+const searchPageSize = 10
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Search = searchPage{Query: r.URL.Query().Get("q"), Page: 1, PageSize: searchPageSize}
+
+	if data.Search.Query != "" {
+		page, ok := pageParam(r)
+		if !ok {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		results, err := app.searchSnippets(data.Search.Query, page, searchPageSize)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data.Search = results
+	}
+
+	app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+// searchResultJSON is the API representation of a search result.
+type searchResultJSON struct {
+	Snippet snippetJSON       `json:"snippet"`
+	Score   float64           `json:"score"`
+	Title   []search.Fragment `json:"title"`
+	Excerpt []search.Fragment `json:"excerpt"`
+}
+
+// apiSearch handles GET /api/v1/search?q=...&page=N&page_size=M.
+func (app *application) apiSearch(w http.ResponseWriter, r *http.Request) {
+	errs := map[string]string{}
+
+	query := r.URL.Query().Get("q")
+	if !validator.NotBlank(query) {
+		errs["q"] = "must not be blank"
+	}
+
+	page, ok := pageParam(r)
+	if !ok {
+		errs["page"] = "must be a positive integer"
+	}
+
+	pageSize := defaultPageSize
+	if v := r.URL.Query().Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			errs["page_size"] = fmt.Sprintf("must be an integer between 1 and %d", maxPageSize)
+		}
+		pageSize = n
+	}
+
+	if len(errs) > 0 {
+		app.writeProblem(w, r, problem{
+			Status: http.StatusBadRequest,
+			Detail: "Invalid query parameters.",
+			Errors: errs,
+		})
+		return
+	}
+
+	results, err := app.searchSnippets(query, page, pageSize)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	items := make([]searchResultJSON, len(results.Results))
+	for i, res := range results.Results {
+		items[i] = searchResultJSON{
+			Snippet: newSnippetJSON(res.Snippet),
+			Score:   res.Score,
+			Title:   res.Title,
+			Excerpt: res.Excerpt,
+		}
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string]any{
+		"query":   query,
+		"results": items,
+		"metadata": map[string]int{
+			"page":      results.Page,
+			"page_size": results.PageSize,
+			"total":     results.Total,
+			"last_page": results.LastPage(),
+		},
+	})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// tlsConfig returns the TLS settings for the HTTPS server. TLS 1.2 is the
+// minimum; for it only forward-secret AEAD cipher suites are allowed (TLS 1.3
+// suites aren't configurable and are all fine). The curve list prefers the
+// post-quantum hybrid key exchange, then the fast, constant-time curves.
+func tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519MLKEM768,
+			tls.X25519,
+			tls.CurveP256,
+		},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// newServer returns an http.Server for handler with explicit limits, so that
+// slow or malicious clients can't hold connections open indefinitely or send
+// huge headers. Errors from the server itself go to the structured logger.
+func newServer(addr string, handler http.Handler, logger *slog.Logger) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      15 * time.Second,
+		IdleTimeout:       time.Minute,
+		MaxHeaderBytes:    64 << 10,
+	}
+}
+
+// redirectToHTTPS returns a handler which sends every request to the same
+// host and path on the HTTPS listener at httpsAddr, with a permanent
+// redirect.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// No port in the Host header.
+			host = r.Host
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+// serve runs the HTTP(S) server, and the optional redirect and admin
+// listeners, until SIGINT or SIGTERM is received. It then shuts down
+// gracefully:
+//
+//  1. fail the readiness check, and keep serving for cfg.drainDelay so that
+//     load balancers polling /readyz stop sending new requests;
+//  2. stop accepting new connections and wait for in-flight requests to
+//     finish, up to cfg.shutdownTimeout;
+//  3. cancel the background workers' shared context and wait for them to
+//     return, again for up to cfg.shutdownTimeout.
+//
+// A second signal during the drain kills the process immediately. serve
+// returns nil only if everything stopped within the deadline.
+func (app *application) serve(cfg config, handler http.Handler) error {
+	srv := newServer(cfg.addr, handler, app.logger)
+
+	// Every server sends exactly one error when it stops, so the channel
+	// has room for all of them.
+	servers := []*http.Server{srv}
+	serverErrors := make(chan error, 3)
+
+	if cfg.useTLS() {
+		srv.TLSConfig = tlsConfig()
+
+		go func() {
+			app.logger.Info("starting server", "addr", srv.Addr, "tls", true)
+			serverErrors <- srv.ListenAndServeTLS(cfg.tls.certFile, cfg.tls.keyFile)
+		}()
+
+		if cfg.tls.redirectAddr != "" {
+			redirect := newServer(cfg.tls.redirectAddr, redirectToHTTPS(srv.Addr), app.logger)
+			servers = append(servers, redirect)
+
+			go func() {
+				app.logger.Info("starting HTTP to HTTPS redirect", "addr", redirect.Addr)
+				serverErrors <- redirect.ListenAndServe()
+			}()
+		}
+	} else {
+		go func() {
+			app.logger.Info("starting server", "addr", srv.Addr)
+			serverErrors <- srv.ListenAndServe()
+		}()
+	}
+
+	if cfg.adminAddr != "" {
+		admin := newServer(cfg.adminAddr, app.adminRoutes(), app.logger)
+		servers = append(servers, admin)
+
+		go func() {
+			app.logger.Info("starting admin server", "addr", admin.Addr)
+			serverErrors <- admin.ListenAndServe()
+		}()
+	}
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	var errs []error
+	running := len(servers)
+
+	signalled := false
+	select {
+	case err := <-serverErrors:
+		// A listener failed to start (or died), for example because the
+		// address is already in use. Shut down whatever else is running.
+		errs = append(errs, err)
+		running--
+	case <-ctx.Done():
+		app.logger.Info("shutting down", "signal", context.Cause(ctx).Error(), "timeout", cfg.shutdownTimeout)
+		signalled = true
+	}
+
+	// Fail readiness from now on, so that nothing new is routed here.
+	app.draining.Store(true)
+
+	// Restore the default signal behaviour, so that a second Ctrl-C stops
+	// the process straight away if draining takes too long.
+	stopSignals()
+
+	// Keep serving while load balancers notice, unless a listener has
+	// already failed.
+	if signalled && cfg.drainDelay > 0 {
+		app.logger.Info("waiting for load balancers", "delay", cfg.drainDelay)
+		time.Sleep(cfg.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancel()
+
+	// Servers are shut down one after the other, in the order they were
+	// added, so the admin server keeps answering /readyz (with a failure)
+	// until the public one has drained.
+	for _, s := range servers {
+		err := s.Shutdown(shutdownCtx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("draining %s: %w", s.Addr, err))
+		}
+	}
+
+	// The workers get their own deadline, so that a slow drain doesn't leave
+	// them no time at all to finish what they're doing.
+	workersCtx, cancelWorkers := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancelWorkers()
+
+	err := app.workers.stop(workersCtx)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// Once Shutdown has been called, every ListenAndServe call still
+	// running returns http.ErrServerClosed, which is expected rather than a
+	// failure.
+	for ; running > 0; running-- {
+		err := <-serverErrors
+		if !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"time"
+
+	"web-application.antoine.example/internal/cache"
+	"web-application.antoine.example/internal/models"
+)
+
+// cachedSnippetModel wraps a SnippetModel with a read-through cache for Get,
+// which is what every snippet page and API lookup ends up calling. Listings
+// go straight to the wrapped model. Any method which changes a snippet must
+// invalidate it here, or readers would see the old version until the TTL
+// runs out.
+type cachedSnippetModel struct {
+	models.SnippetModel
+	cache *cache.Cache[int, models.Snippet]
+}
+
+// snippetOverhead is a rough estimate of the memory a cached snippet uses
+// besides its title and content.
+const snippetOverhead = 256
+
+// newCachedSnippetModel returns m behind a cache which keeps snippets for ttl
+// and holds up to roughly maxBytes of them.
+func newCachedSnippetModel(m models.SnippetModel, ttl time.Duration, maxBytes int) *cachedSnippetModel {
+	return &cachedSnippetModel{
+		SnippetModel: m,
+		cache: cache.New(cache.Options[int, models.Snippet]{
+			TTL:     ttl,
+			MaxSize: maxBytes,
+			Size: func(s models.Snippet) int {
+				return snippetOverhead + len(s.Title) + len(s.Content)
+			},
+			Load: m.Get,
+		}),
+	}
+}
+
+func (m *cachedSnippetModel) Get(id int) (models.Snippet, error) {
+	snippet, err := m.cache.Get(id)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	// The snippet may have expired since it was cached. The reaper will
+	// delete it, but until then it must look as if it's already gone.
+	if snippet.Expired() {
+		m.cache.Invalidate(id)
+		return models.Snippet{}, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+func (m *cachedSnippetModel) Update(id int, u models.SnippetUpdate) (models.Revision, error) {
+	rev, err := m.SnippetModel.Update(id, u)
+	m.cache.Invalidate(id)
+	return rev, err
+}
+
+func (m *cachedSnippetModel) Delete(id int) error {
+	err := m.SnippetModel.Delete(id)
+	m.cache.Invalidate(id)
+	return err
+}
+
+func (m *cachedSnippetModel) DeleteExpired() ([]int, error) {
+	ids, err := m.SnippetModel.DeleteExpired()
+	for _, id := range ids {
+		m.cache.Invalidate(id)
+	}
+	return ids, err
+}
+
+// stats returns the cache's counters. It is safe to call on a nil
+// *cachedSnippetModel, when the cache is disabled, and returns zero values.
+func (m *cachedSnippetModel) stats() cache.Stats {
+	if m == nil {
+		return cache.Stats{}
+	}
+	return m.cache.Stats()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticAsset is a static file loaded into memory along with its content
+// hash.
+type staticAsset struct {
+	content []byte
+	modTime time.Time
+	hash    string
+}
+
+// staticFiles serves the files under ui/static. In production they come from
+// the embedded filesystem and never change, so each file is read and hashed
+// once. In development (fromDisk) they are re-read on every request, so edits
+// show up without a rebuild.
+//
+// Templates link to assets through the "static" template function, which
+// returns a fingerprinted name such as /static/css/main.3f2a9c1b04d5e6f7.css.
+// A fingerprinted URL can never point at different content, so it is sent
+// with a one year, immutable Cache-Control header. Plain names are still
+// served, but must be revalidated with their ETag.
+type staticFiles struct {
+	fsys     fs.FS
+	fromDisk bool
+
+	mu     sync.RWMutex
+	assets map[string]*staticAsset
+}
+
+func newStaticFiles(fsys fs.FS, fromDisk bool) *staticFiles {
+	return &staticFiles{
+		fsys:     fsys,
+		fromDisk: fromDisk,
+		assets:   make(map[string]*staticAsset),
+	}
+}
+
+// load returns the asset at name, reading and hashing it if needed.
+// Directories are reported as fs.ErrNotExist so they are never listed.
+func (s *staticFiles) load(name string) (*staticAsset, error) {
+	if !s.fromDisk {
+		s.mu.RLock()
+		asset, ok := s.assets[name]
+		s.mu.RUnlock()
+		if ok {
+			return asset, nil
+		}
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	asset := &staticAsset{
+		content: content,
+		modTime: info.ModTime(),
+		hash:    hex.EncodeToString(sum[:]),
+	}
+
+	if !s.fromDisk {
+		s.mu.Lock()
+		s.assets[name] = asset
+		s.mu.Unlock()
+	}
+
+	return asset, nil
+}
+
+// fingerprintLen is the number of hex characters of the content hash that
+// are inserted into fingerprinted file names.
+const fingerprintLen = 16
+
+// url returns the URL templates should use for the named asset. Outside of
+// development mode the name carries a fingerprint of the file contents. If
+// the file can't be read the plain URL is returned, and the request will 404
+// in the usual way.
+func (s *staticFiles) url(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if s.fromDisk {
+		return "/static/" + name
+	}
+
+	asset, err := s.load(name)
+	if err != nil {
+		return "/static/" + name
+	}
+
+	ext := path.Ext(name)
+	return "/static/" + strings.TrimSuffix(name, ext) + "." + asset.hash[:fingerprintLen] + ext
+}
+
+// splitFingerprint turns "css/main.0123456789abcdef.css" into "css/main.css"
+// and "0123456789abcdef". It returns ok == false for names without a
+// fingerprint.
+func splitFingerprint(name string) (plain, fingerprint string, ok bool) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 || len(base)-dot-1 != fingerprintLen {
+		return "", "", false
+	}
+
+	fingerprint = base[dot+1:]
+	if _, err := hex.DecodeString(fingerprint); err != nil {
+		return "", "", false
+	}
+
+	return base[:dot] + ext, fingerprint, true
+}
+
+func (s *staticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	immutable := false
+
+	asset, err := s.load(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// The name might be fingerprinted. Only accept it if the fingerprint
+		// matches the current contents, otherwise a stale URL would be cached
+		// for a year with the wrong file behind it.
+		plain, fingerprint, ok := splitFingerprint(name)
+		if ok {
+			asset, err = s.load(plain)
+			if err == nil && !strings.HasPrefix(asset.hash, fingerprint) {
+				err = fs.ErrNotExist
+			}
+			name = plain
+			immutable = !s.fromDisk
+		}
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// A strong ETag derived from the contents. http.ServeContent uses it to
+	// answer If-None-Match with a 304.
+	w.Header().Set("ETag", `"`+asset.hash[:32]+`"`)
+	if immutable {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	http.ServeContent(w, r, name, asset.modTime, bytes.NewReader(asset.content))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"web-application.antoine.example/internal/highlight"
+	"web-application.antoine.example/internal/models"
+	"web-application.antoine.example/ui"
+)
+
+// templateData acts as the holding structure for any dynamic data that we
+// want to pass to our HTML templates. The common fields (CurrentYear, Flash,
+// IsAuthenticated, CSRFToken) are filled in by newTemplateData() for every
+// page.
+type templateData struct {
+	CurrentYear     int
+	Snippet         models.Snippet
+	Listing         snippetListing
+	Author          string
+	Highlighted     template.HTML
+	Rendered        template.HTML
+	History         []revisionItem
+	Diff            revisionDiff
+	CanEdit         bool
+	EmbedCode       string
+	Search          searchPage
+	Form            any
+	Flash           string
+	IsAuthenticated bool
+	CSRFToken       string
+}
+
+// humanDate returns a nicely formatted string representation of a time.Time
+// object.
+func humanDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("02 Jan 2006 at 15:04")
+}
+
+// humanAge describes how long ago t was, in the largest whole unit that fits
+// ("3 hours ago"). Anything older than a month falls back to humanDate.
+func humanAge(t time.Time) string {
+	d := time.Since(t)
+
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit + " ago"
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	case d < 30*24*time.Hour:
+		return plural(int(d/(24*time.Hour)), "day")
+	default:
+		return humanDate(t)
+	}
+}
+
+// excerpt returns the start of s, with runs of whitespace collapsed to a
+// single space, cut at a word boundary so it is at most n characters long.
+func excerpt(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	runes := []rune(s)[:n]
+	if i := strings.LastIndexByte(string(runes), ' '); i > 0 {
+		return string(runes)[:i] + "…"
+	}
+	return string(runes) + "…"
+}
+
+// languageLabel returns the display name of a language, or an empty string
+// if it isn't known.
+func languageLabel(name string) string {
+	l, ok := highlight.Lookup(name)
+	if !ok {
+		return ""
+	}
+	return l.Label
+}
+
+// add returns a + b, for revision numbers: templates have no arithmetic of
+// their own.
+func add(a, b int) int {
+	return a + b
+}
+
+// newTemplateCache parses every page in ui/html/pages together with the base
+// layout and the partials, and returns the result keyed by page file name
+// (for example "home.tmpl"). It is called once at startup.
+func newTemplateCache(static *staticFiles) (map[string]*template.Template, error) {
+	cache := map[string]*template.Template{}
+
+	// The functions made available to every template. They must be
+	// registered before the templates are parsed.
+	functions := template.FuncMap{
+		"humanDate": humanDate,
+		"humanAge":  humanAge,
+		"excerpt":   excerpt,
+		"join":      strings.Join,
+		"add":       add,
+		"languages": highlight.Languages,
+		"language":  languageLabel,
+		"static":    static.url,
+	}
+
+	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range pages {
+		name := filepath.Base(page)
+
+		// The base template must come first, followed by the partials and
+		// then the page itself.
+		patterns := []string{
+			"html/base.tmpl",
+			"html/partials/*.tmpl",
+			page,
+		}
+
+		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+		if err != nil {
+			return nil, err
+		}
+
+		cache[name] = ts
+	}
+
+	// The embed view is shown inside other sites' pages, so it has a layout
+	// of its own, without the header, navigation and footer.
+	ts, err := template.New("embed.tmpl").Funcs(functions).ParseFS(ui.Files, "html/embed.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	cache["embed.tmpl"] = ts
+
+	return cache, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"web-application.antoine.example/internal/health"
+	"web-application.antoine.example/internal/highlight"
+	"web-application.antoine.example/internal/markdown"
+	"web-application.antoine.example/internal/models"
+	"web-application.antoine.example/internal/search"
+	"web-application.antoine.example/internal/sessions"
+	"web-application.antoine.example/ui"
+)
+
+// noEnv is a lookupEnv for loadConfig with no variables set.
+func noEnv(string) (string, bool) {
+	return "", false
+}
+
+// newTestApplication returns an application with in-memory storage and the
+// default configuration, along with the handler for its routes. Log output is
+// discarded.
+func newTestApplication(t *testing.T) (*application, http.Handler) {
+	t.Helper()
+
+	cfg, _, err := loadConfig(nil, noEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	staticFS, err := fs.Sub(ui.Files, "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	static := newStaticFiles(staticFS, false)
+
+	templateCache, err := newTemplateCache(static)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchIndex := search.NewIndex()
+	snippets, err := newIndexedSnippetModel(models.NewMemorySnippetModel(), searchIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		logger:         logger,
+		snippets:       snippets,
+		users:          models.NewMemoryUserModel(),
+		templateCache:  templateCache,
+		sessionManager: sessions.New(sessions.NewMemoryStore()),
+		workers:        newBackgroundWorkers(logger),
+		searchIndex:    searchIndex,
+		highlights:     highlight.NewCache(renderCacheSize, highlight.Highlight),
+		markdown:       highlight.NewCache(renderCacheSize, markdown.Render),
+		limiters:       newRateLimiters(cfg),
+		metrics:        newAppMetrics(nil),
+		embedAncestors: cfg.embedAncestors,
+		publicURL:      cfg.publicURL,
+		liveness:       health.New(healthCheckTimeout, logger),
+		readiness:      health.New(healthCheckTimeout, logger),
+	}
+	app.sessionManager.ErrorFunc = app.serverError
+
+	return app, app.routes(static)
+}
+
+// newTestServer starts a server for h which is closed when the test ends.
+// Its client doesn't follow redirects, so tests see them as they are sent.
+func newTestServer(t *testing.T, h http.Handler) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return ts
+}
+
+// testClient is a browser for a test server: it keeps its own cookies, so
+// each testClient has its own session, and doesn't follow redirects.
+type testClient struct {
+	t      *testing.T
+	ts     *httptest.Server
+	client *http.Client
+}
+
+func newTestClient(t *testing.T, ts *httptest.Server) *testClient {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := *ts.Client()
+	client.Jar = jar
+	return &testClient{t: t, ts: ts, client: &client}
+}
+
+// do sends a request for path with the given headers and body, and returns
+// the response along with its body.
+func (c *testClient) do(method, path string, header http.Header, body io.Reader) (*http.Response, string) {
+	c.t.Helper()
+
+	req, err := http.NewRequest(method, c.ts.URL+path, body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return res, string(b)
+}
+
+func (c *testClient) get(path string) (*http.Response, string) {
+	c.t.Helper()
+	return c.do(http.MethodGet, path, nil, nil)
+}
+
+// postForm submits form to path, as a browser on the same site would.
+func (c *testClient) postForm(path string, form url.Values) (*http.Response, string) {
+	c.t.Helper()
+
+	header := http.Header{
+		"Content-Type":   {"application/x-www-form-urlencoded"},
+		"Sec-Fetch-Site": {"same-origin"},
+	}
+	return c.do(http.MethodPost, path, header, strings.NewReader(form.Encode()))
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="([^"]+)">`)
+
+// csrfToken loads the page at path and returns the CSRF token in its form.
+func (c *testClient) csrfToken(path string) string {
+	c.t.Helper()
+
+	_, body := c.get(path)
+	m := csrfTokenRX.FindStringSubmatch(body)
+	if m == nil {
+		c.t.Fatalf("no CSRF token in %s", path)
+	}
+	return html.UnescapeString(m[1])
+}
+
+// signup creates an account through the signup form.
+func (c *testClient) signup(name, email, password string) {
+	c.t.Helper()
+
+	res, _ := c.postForm("/user/signup", url.Values{
+		"name":       {name},
+		"email":      {email},
+		"password":   {password},
+		"csrf_token": {c.csrfToken("/user/signup")},
+	})
+	if res.StatusCode != http.StatusSeeOther {
+		c.t.Fatalf("signup: got status %d", res.StatusCode)
+	}
+}
+
+// login logs in through the login form and returns the response, which
+// redirects to wherever the user is sent next.
+func (c *testClient) login(email, password string) *http.Response {
+	c.t.Helper()
+
+	res, _ := c.postForm("/user/login", url.Values{
+		"email":      {email},
+		"password":   {password},
+		"csrf_token": {c.csrfToken("/user/login")},
+	})
+	if res.StatusCode != http.StatusSeeOther {
+		c.t.Fatalf("login: got status %d", res.StatusCode)
+	}
+	return res
+}
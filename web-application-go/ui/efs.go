@@ -0,0 +1,9 @@
+package ui
+
+import "embed"
+
+// Files holds the HTML templates and static assets, compiled into the binary
+// so that deploying the server means copying a single file.
+//
+//go:embed "html" "static"
+var Files embed.FS
@@ -0,0 +1,42 @@
+// Embeds a snippet in another site's page. Add
+//
+//   <script src="https://snippetbox.example.com/static/js/embed.js" data-snippet="42" async></script>
+//
+// where the snippet should appear, and the script puts an iframe showing
+// snippet 42 in its place. The view page shows this code for each snippet.
+(function () {
+	var script = document.currentScript;
+	if (!script) {
+		return;
+	}
+
+	var id = script.getAttribute("data-snippet");
+	if (!/^[1-9][0-9]*$/.test(id || "")) {
+		return;
+	}
+
+	// The snippet is served from wherever this script came from.
+	var origin = new URL(script.src).origin;
+
+	var frame = document.createElement("iframe");
+	frame.src = origin + "/snippet/embed/" + id;
+	frame.title = "Snippet #" + id;
+	frame.loading = "lazy";
+	frame.style.display = "block";
+	frame.style.width = "100%";
+	frame.style.height = "240px";
+	frame.style.border = "0";
+	script.parentNode.insertBefore(frame, script.nextSibling);
+
+	// frame.js reports the height of the snippet once it has loaded. Other
+	// frames on the page may send messages too, so only this one counts.
+	window.addEventListener("message", function (event) {
+		if (event.origin !== origin || event.source !== frame.contentWindow) {
+			return;
+		}
+		var data = event.data;
+		if (data && data.type === "snippetbox:resize" && typeof data.height === "number") {
+			frame.style.height = Math.ceil(data.height) + "px";
+		}
+	});
+})();
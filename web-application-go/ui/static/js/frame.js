@@ -0,0 +1,17 @@
+// Runs inside the embed view. Tell the page around the frame how tall the
+// snippet is, so that embed.js can size the iframe to fit without a scroll
+// bar. The height is all that is sent, so any page may receive it.
+function postHeight() {
+	var height = document.body.scrollHeight;
+	window.parent.postMessage({type: "snippetbox:resize", height: height}, "*");
+}
+
+if (window.parent !== window) {
+	postHeight();
+	window.addEventListener("load", postHeight);
+	if (window.ResizeObserver) {
+		new ResizeObserver(postHeight).observe(document.body);
+	} else {
+		window.addEventListener("resize", postHeight);
+	}
+}
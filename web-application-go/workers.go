@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backgroundWorkers tracks the long-running goroutines the server starts
+// (cleanup loops, reapers and the like) so they can all be stopped together
+// during shutdown. Every worker gets the same context, which is cancelled by
+// stop().
+type backgroundWorkers struct {
+	logger *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// exited lists the workers which returned (or panicked) before stop
+	// was called. None of them is meant to, so any name here is a bug.
+	mu     sync.Mutex
+	exited []string
+}
+
+func newBackgroundWorkers(logger *slog.Logger) *backgroundWorkers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backgroundWorkers{logger: logger, ctx: ctx, cancel: cancel}
+}
+
+// start runs fn in a new goroutine. fn must return promptly once ctx is
+// cancelled. A panic in fn is logged rather than crashing the server.
+func (b *backgroundWorkers) start(name string, fn func(ctx context.Context)) {
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+		defer func() {
+			if pv := recover(); pv != nil {
+				b.logger.Error("background worker panicked", "worker", name, "panic", fmt.Sprint(pv))
+			}
+			if b.ctx.Err() == nil {
+				b.mu.Lock()
+				b.exited = append(b.exited, name)
+				b.mu.Unlock()
+			}
+		}()
+
+		b.logger.Debug("background worker started", "worker", name)
+		fn(b.ctx)
+		b.logger.Debug("background worker stopped", "worker", name)
+	}()
+}
+
+// every is a helper for the common case of a worker which calls fn on a
+// fixed interval until it is stopped.
+func (b *backgroundWorkers) every(name string, interval time.Duration, fn func()) {
+	b.start(name, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// check returns an error naming any worker which has stopped although the
+// server is still running, for the readiness probe.
+func (b *backgroundWorkers) check(context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.exited) > 0 {
+		return fmt.Errorf("background workers not running: %s", strings.Join(b.exited, ", "))
+	}
+	return nil
+}
+
+// stop cancels the workers' context and waits for all of them to return, or
+// for ctx to be done, whichever comes first.
+func (b *backgroundWorkers) stop(ctx context.Context) error {
+	b.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for background workers: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"web-application.antoine.example/internal/assert"
+)
+
+func TestBackgroundWorkersStop(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b := newBackgroundWorkers(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+		ticks := 0
+		b.every("ticker", time.Minute, func() { ticks++ })
+
+		time.Sleep(3*time.Minute + time.Second)
+		synctest.Wait()
+		assert.Equal(t, ticks, 3)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NilError(t, b.stop(ctx))
+
+		// The worker has returned, so no more ticks arrive.
+		time.Sleep(time.Hour)
+		assert.Equal(t, ticks, 3)
+		assert.NilError(t, b.check(ctx))
+	})
+}
+
+// TestBackgroundWorkersStopTimeout checks that stop gives up on a worker
+// which doesn't return once its context is cancelled.
+func TestBackgroundWorkersStopTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b := newBackgroundWorkers(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+		release := make(chan struct{})
+		b.start("stuck", func(context.Context) { <-release })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		err := b.stop(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, time.Since(start), 5*time.Second)
+
+		close(release)
+	})
+}
+
+// TestBackgroundWorkersCheck checks that a worker which stops early, or
+// panics, fails readiness without taking the others down.
+func TestBackgroundWorkersCheck(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		b := newBackgroundWorkers(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+		b.start("running", func(ctx context.Context) { <-ctx.Done() })
+		synctest.Wait()
+		assert.NilError(t, b.check(context.Background()))
+
+		b.start("returns", func(context.Context) {})
+		b.start("panics", func(context.Context) { panic("boom") })
+		synctest.Wait()
+
+		err := b.check(context.Background())
+		if err == nil {
+			t.Fatal("got no error; want the stopped workers named")
+		}
+		assert.StringContains(t, err.Error(), "returns")
+		assert.StringContains(t, err.Error(), "panics")
+
+		assert.NilError(t, b.stop(context.Background()))
+	})
+}